@@ -3,10 +3,12 @@ package bitcoin
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"math/rand"
 	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/shopspring/decimal"
@@ -14,7 +16,9 @@ import (
 	"github.com/zsmartex/multichain/pkg/block"
 	"github.com/zsmartex/multichain/pkg/blockchain"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/watchlist"
 )
 
 type VOut struct {
@@ -31,25 +35,42 @@ type Vin struct {
 }
 
 type TxHash struct {
-	TxID string  `json:"txid"`
-	Vin  []*Vin  `json:"vin"`
-	VOut []*VOut `json:"vout"`
+	TxID          string  `json:"txid"`
+	BlockHash     string  `json:"blockhash"` // empty while the transaction is in the mempool
+	Confirmations int64   `json:"confirmations"`
+	Vin           []*Vin  `json:"vin"`
+	VOut          []*VOut `json:"vout"`
+}
+
+type MempoolEntry struct {
+	Time int64 `json:"time"` // when the transaction entered the mempool
 }
 
 type Block struct {
-	Hash          string    `json:"hash"`
-	Confirmations int       `json:"confirmations"`
-	Size          int       `json:"size"`
-	Height        int64     `json:"height"`
-	Version       int       `json:"version"`
-	MerkleRoot    string    `json:"merkleroot"`
-	Tx            []*TxHash `json:"tx"`
+	Hash              string    `json:"hash"`
+	PreviousBlockHash string    `json:"previousblockhash"`
+	Confirmations     int64     `json:"confirmations"` // -1 when the block is not in the main chain
+	Size              int       `json:"size"`
+	Height            int64     `json:"height"`
+	Version           int       `json:"version"`
+	MerkleRoot        string    `json:"merkleroot"`
+	Time              int64     `json:"time"`
+	NTx               int       `json:"nTx"`
+	Tx                []*TxHash `json:"tx"`
 }
 
 type Blockchain struct {
 	currency *currency.Currency
 	setting  *blockchain.Setting
 	client   *resty.Client
+	closed   int32 // set by Close, the calls made afterwards fail with ErrClosed
+
+	watchlist watchlist.Watchlist
+	pending   blockchain.PendingTracker // the transactions seen in the mempool
+}
+
+func init() {
+	blockchain.Register("bitcoin", NewBlockchain)
 }
 
 func NewBlockchain() blockchain.Blockchain {
@@ -58,22 +79,85 @@ func NewBlockchain() blockchain.Blockchain {
 	}
 }
 
-func (b *Blockchain) Configure(settings *blockchain.Setting) {
+func (b *Blockchain) Configure(settings *blockchain.Setting) error {
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("failed to configure bitcoin blockchain: %w", err)
+	}
+
+	if err := validateURI(settings.URI); err != nil {
+		return fmt.Errorf("failed to configure bitcoin blockchain: %w", err)
+	}
+
+	// allow only one currency
+	if len(settings.Currencies) > 1 {
+		return fmt.Errorf("failed to configure bitcoin blockchain: %w: only one currency is supported", errors.ErrInvalidConfiguration)
+	}
+
 	b.setting = settings
+	b.currency = settings.Currencies[0]
+	b.watchlist = settings.AddressFilter()
+	atomic.StoreInt32(&b.closed, 0)
+
+	return nil
+}
+
+// validateURI check that uri is the http(s) url of a bitcoind json-rpc server,
+// the credentials may be given as its user info
+func validateURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("%w: uri is invalid: %v", errors.ErrInvalidConfiguration, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: uri %s must be a http(s) url", errors.ErrInvalidConfiguration, uri)
+	}
+
+	if len(u.Host) == 0 {
+		return fmt.Errorf("%w: uri %s has no host", errors.ErrInvalidConfiguration, uri)
+	}
+
+	return nil
+}
+
+func (b *Blockchain) Health(ctx context.Context) error {
+	if b.setting == nil {
+		return fmt.Errorf("%w: bitcoin blockchain is not configured", errors.ErrInvalidConfiguration)
+	}
+
+	var resp struct {
+		Blocks               int64 `json:"blocks"`
+		Headers              int64 `json:"headers"`
+		InitialBlockDownload bool  `json:"initialblockdownload"`
+	}
+	if err := b.jsonRPC(ctx, &resp, "getblockchaininfo"); err != nil {
+		return err
+	}
 
-	for _, c := range settings.Currencies {
-		// allow only one currency
-		b.currency = c
-		break
+	if resp.InitialBlockDownload {
+		return fmt.Errorf("%w: node is in initial block download (%d/%d)", errors.ErrNodeUnavailable, resp.Blocks, resp.Headers)
 	}
+
+	return nil
+}
+
+func (b *Blockchain) Close() error {
+	atomic.StoreInt32(&b.closed, 1)
+	b.client.GetClient().CloseIdleConnections()
+
+	return nil
 }
 
 func (b *Blockchain) jsonRPC(ctx context.Context, resp interface{}, method string, params ...interface{}) error {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return errors.ErrClosed
+	}
+
 	type Result struct {
 		Version string           `json:"version"`
 		ID      int              `json:"id"`
 		Result  *json.RawMessage `json:"result"`
-		Error   *json.RawMessage `json:"error"`
+		Error   *rpcError        `json:"error"`
 	}
 
 	uri, err := url.Parse(b.setting.URI)
@@ -85,6 +169,7 @@ func (b *Blockchain) jsonRPC(ctx context.Context, resp interface{}, method strin
 		R().
 		SetContext(ctx).
 		SetResult(Result{}).
+		SetError(Result{}).
 		SetHeaders(map[string]string{
 			"Accept":       "application/json",
 			"Content-Type": "application/json",
@@ -98,13 +183,22 @@ func (b *Blockchain) jsonRPC(ctx context.Context, resp interface{}, method strin
 		Post(uri.JoinPath(method).String())
 
 	if err != nil {
-		return err
+		return errors.Wrap(errors.ErrNodeUnavailable, err)
 	}
 
-	result := response.Result().(*Result)
+	var result *Result
+	if response.IsError() {
+		result = response.Error().(*Result)
+	} else {
+		result = response.Result().(*Result)
+	}
 
 	if result.Error != nil {
-		return errors.New("jsonRPC error: " + string(*result.Error))
+		return normalizeError(result.Error)
+	}
+
+	if response.IsError() {
+		return normalizeStatusError(response.StatusCode(), response.Status())
 	}
 
 	if result.Result == nil {
@@ -143,25 +237,43 @@ func (b *Blockchain) GetBlockByHash(ctx context.Context, hash string) (*block.Bl
 		return nil, err
 	}
 
+	// the transactions spending an output of the same block find their sender without a lookup
+	known := make(map[string]*TxHash, len(resp.Tx))
+	for _, tx := range resp.Tx {
+		known[tx.TxID] = tx
+	}
+
 	transactions := make([]*transaction.Transaction, 0)
 	for _, tx := range resp.Tx {
-		txs := b.buildTransaction(ctx, tx)
+		txs, err := b.buildTransaction(ctx, tx, known)
+		if err != nil {
+			return nil, err
+		}
 
 		for _, tx := range txs {
 			tx.BlockNumber = resp.Height
+			tx.Confirmations = null.Int64From(countConfirmations(resp.Confirmations))
 		}
 
 		transactions = append(transactions, txs...)
 	}
 
 	return &block.Block{
-		Hash:         resp.Hash,
-		Number:       resp.Height,
-		Transactions: transactions,
+		Hash:             resp.Hash,
+		ParentHash:       resp.PreviousBlockHash,
+		Number:           resp.Height,
+		Timestamp:        time.Unix(resp.Time, 0),
+		Size:             int64(resp.Size),
+		TransactionCount: resp.NTx,
+		Transactions:     transactions,
 	}, nil
 }
 
 func (b *Blockchain) GetBalanceOfAddress(ctx context.Context, address string, currencyID string) (decimal.Decimal, error) {
+	if currencyID != b.currency.ID {
+		return decimal.Zero, fmt.Errorf("%w: %s", errors.ErrUnknownCurrency, currencyID)
+	}
+
 	var resp [][][]interface{}
 	if err := b.jsonRPC(ctx, &resp, "listaddressgroupings"); err != nil {
 		return decimal.Zero, err
@@ -178,12 +290,29 @@ func (b *Blockchain) GetBalanceOfAddress(ctx context.Context, address string, cu
 	return decimal.Zero, errors.New("unavailable address balance")
 }
 
+// GetTransaction return the outputs of transaction_hash, they have StatusPending while it is in the mempool
+// and the error is ErrTransactionDropped once a transaction seen in the mempool is neither there nor in a block,
+// transactions which are not in the node wallet are only found in blocks when the node has txindex
 func (b *Blockchain) GetTransaction(ctx context.Context, transaction_hash string) ([]*transaction.Transaction, error) {
-	var resp *TxHash
-	if err := b.jsonRPC(ctx, &resp, "getrawtransaction", transaction_hash, 1); err != nil {
+	resp, err := b.getRawTransaction(ctx, transaction_hash)
+	if err != nil {
 		return nil, err
 	}
 
+	status := transaction.StatusSucceed
+	var firstSeen null.Time
+	if len(resp.BlockHash) == 0 {
+		status = transaction.StatusPending
+
+		var entry *MempoolEntry
+		if err := b.jsonRPC(ctx, &entry, "getmempoolentry", resp.TxID); err == nil {
+			firstSeen = null.TimeFrom(time.Unix(entry.Time, 0))
+		} else if !errors.Is(err, errors.ErrTransactionNotFound) {
+			// not found means it was mined or dropped since getrawtransaction
+			return nil, err
+		}
+	}
+
 	transactions := make([]*transaction.Transaction, 0)
 
 	for _, v := range b.buildVOut(resp.VOut) {
@@ -193,20 +322,62 @@ func (b *Blockchain) GetTransaction(ctx context.Context, transaction_hash string
 		}
 
 		transactions = append(transactions, &transaction.Transaction{
-			TxHash:      null.StringFrom(resp.TxID),
-			ToAddress:   v.ScriptPubKey.Addresses[0],
-			Currency:    b.currency.ID,
-			CurrencyFee: b.currency.ID,
-			TxOut:       v.N,
-			Fee:         decimal.NewNullDecimal(fee),
-			Amount:      v.Value,
-			Status:      transaction.StatusSucceed,
+			TxHash:        null.StringFrom(resp.TxID),
+			ToAddress:     v.ScriptPubKey.Addresses[0],
+			Currency:      b.currency.ID,
+			CurrencyFee:   b.currency.ID,
+			TxOut:         v.N,
+			Fee:           decimal.NewNullDecimal(fee),
+			Amount:        v.Value,
+			Status:        status,
+			FirstSeen:     firstSeen,
+			Confirmations: null.Int64From(resp.Confirmations),
 		})
 	}
 
 	return transactions, nil
 }
 
+// GetConfirmations return the confirmations of transaction_hash, 0 while it is in the mempool
+func (b *Blockchain) GetConfirmations(ctx context.Context, transaction_hash string) (int64, error) {
+	resp, err := b.getRawTransaction(ctx, transaction_hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Confirmations, nil
+}
+
+// getRawTransaction return the transaction of hash from the mempool or the blocks, the error
+// is ErrTransactionDropped when the node doesn't know anymore a transaction seen in the mempool
+func (b *Blockchain) getRawTransaction(ctx context.Context, hash string) (*TxHash, error) {
+	var resp *TxHash
+	if err := b.jsonRPC(ctx, &resp, "getrawtransaction", hash, 1); err != nil {
+		if errors.Is(err, errors.ErrTransactionNotFound) && b.pending.SeenPending(hash) {
+			return nil, errors.Wrap(errors.ErrTransactionDropped, err)
+		}
+
+		return nil, err
+	}
+
+	if len(resp.BlockHash) == 0 {
+		b.pending.FirstSeen(hash)
+	} else {
+		b.pending.Forget(hash)
+	}
+
+	return resp, nil
+}
+
+// countConfirmations return 0 instead of the -1 bitcoind has for blocks out of the main chain
+func countConfirmations(confirmations int64) int64 {
+	if confirmations < 0 {
+		return 0
+	}
+
+	return confirmations
+}
+
 func (b *Blockchain) calculateFee(ctx context.Context, tx *TxHash) (decimal.Decimal, error) {
 	vins := decimal.Zero
 	vouts := decimal.Zero
@@ -253,68 +424,80 @@ func (b *Blockchain) buildVOut(vout []*VOut) []*VOut {
 	return nvout
 }
 
-func (b *Blockchain) transactionSource(ctx context.Context, transaction *transaction.Transaction) (string, error) {
-	var transHash *TxHash
-	err := b.jsonRPC(ctx, &transHash, "getrawtransaction", transaction.TxHash.String, 1)
-	if err != nil {
-		return "", err
-	}
-
-	for _, vin := range transHash.Vin {
+// transactionSource return the address of the first input of tx, it is empty for coinbase transactions
+// and when the spent transaction is unknown to a node without txindex, the spent transaction is only
+// fetched when it is not in known
+func (b *Blockchain) transactionSource(ctx context.Context, tx *TxHash, known map[string]*TxHash) (string, error) {
+	for _, vin := range tx.Vin {
 		if len(vin.TxID) == 0 {
 			continue
 		}
 
-		var vinTransaction *TxHash
-		err := b.jsonRPC(ctx, &vinTransaction, "getrawtransaction", vin.TxID, 1)
-		if err != nil {
-			return "", err
+		vinTransaction, ok := known[vin.TxID]
+		if !ok {
+			if err := b.jsonRPC(ctx, &vinTransaction, "getrawtransaction", vin.TxID, 1); errors.Is(err, errors.ErrTransactionNotFound) {
+				return "", nil
+			} else if err != nil {
+				return "", err
+			}
 		}
 
-		var source *VOut
 		for _, vout := range vinTransaction.VOut {
-			if vout.N == vin.VOut {
-				source = vout
+			if vout.N == vin.VOut && len(vout.ScriptPubKey.Addresses) > 0 {
+				return vout.ScriptPubKey.Addresses[0], nil
 			}
 		}
 
-		if len(source.ScriptPubKey.Addresses) == 0 {
-			return "", nil
-		}
-
-		address := source.ScriptPubKey.Addresses[0]
-
-		return address, nil
+		return "", nil
 	}
 
 	return "", nil
 }
 
-func (b *Blockchain) buildTransaction(ctx context.Context, tx *TxHash) []*transaction.Transaction {
+// buildTransaction build a transaction per output of tx, with a watchlist only the outputs
+// to a watched address are kept, or all of them when tx is sent from a watched address.
+// The outputs are matched first since the sender takes a lookup of the spent transaction.
+func (b *Blockchain) buildTransaction(ctx context.Context, tx *TxHash, known map[string]*TxHash) ([]*transaction.Transaction, error) {
 	transactions := make([]*transaction.Transaction, 0)
+
+	outputs := make([]*VOut, 0, len(tx.VOut))
+	watched := make([]*VOut, 0, len(tx.VOut))
 	for _, entry := range tx.VOut {
 		if entry.Value.IsNegative() || entry.ScriptPubKey.Addresses == nil {
 			continue
 		}
 
-		trans := &transaction.Transaction{
+		outputs = append(outputs, entry)
+		if b.watchlist == nil || b.watchlist.Contains(entry.ScriptPubKey.Addresses[0]) {
+			watched = append(watched, entry)
+		}
+	}
+
+	if len(outputs) == 0 {
+		return transactions, nil
+	}
+
+	fromAddress, err := b.transactionSource(ctx, tx, known)
+	if err != nil {
+		return nil, err
+	}
+
+	// a transaction sent from a watched address is kept whole
+	if len(watched) < len(outputs) && len(fromAddress) > 0 && b.watchlist.Contains(fromAddress) {
+		watched = outputs
+	}
+
+	for _, entry := range watched {
+		transactions = append(transactions, &transaction.Transaction{
 			Currency:    b.currency.ID,
 			CurrencyFee: b.currency.ID,
+			FromAddress: fromAddress,
 			ToAddress:   entry.ScriptPubKey.Addresses[0],
 			Amount:      entry.Value,
 			TxHash:      null.StringFrom(tx.TxID),
 			Status:      transaction.StatusSucceed,
-		}
-
-		fromAddress, err := b.transactionSource(ctx, trans)
-		if err != nil {
-
-		}
-
-		trans.FromAddress = fromAddress
-
-		transactions = append(transactions, trans)
+		})
 	}
 
-	return transactions
+	return transactions, nil
 }
@@ -2,15 +2,21 @@ package bitcoin
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/zsmartex/multichain/pkg/blockchain"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
 )
 
-func newBlockchain() blockchain.Blockchain {
+func newBlockchain(t *testing.T) blockchain.Blockchain {
 	bl := NewBlockchain()
-	bl.Configure(&blockchain.Setting{
+	if err := bl.Configure(&blockchain.Setting{
 		URI: "https://young-fragrant-sunset.btc.quiknode.pro",
 		Currencies: []*currency.Currency{
 			{
@@ -18,13 +24,15 @@ func newBlockchain() blockchain.Blockchain {
 				Subunits: 8,
 			},
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	return bl
 }
 
 func TestBlockchain_GetBlockByNumber(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	block, err := bl.GetBlockByNumber(context.Background(), 100001)
 	if err != nil {
@@ -35,7 +43,7 @@ func TestBlockchain_GetBlockByNumber(t *testing.T) {
 }
 
 func TestBlockchain_GetTransaction(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	tx, err := bl.GetTransaction(context.Background(), "f22279937740708024052bd8c3decf01a406a6f33760e3e10b8e4436826f0e59")
 	if err != nil {
@@ -44,3 +52,172 @@ func TestBlockchain_GetTransaction(t *testing.T) {
 
 	t.Log(tx)
 }
+
+func TestBlockchain_GetTransactionStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "getmempoolentry"):
+			w.Write([]byte(`{"result":{"time":1700000000},"error":null,"id":1}`))
+		case body.Params[0] == "mined":
+			w.Write([]byte(`{"result":{"txid":"mined","blockhash":"00ff","confirmations":6,"vin":[],"vout":[{"value":0.5,"n":0,"scriptPubKey":{"addresses":["bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"]}}]},"error":null,"id":1}`))
+		case body.Params[0] == "pending":
+			w.Write([]byte(`{"result":{"txid":"pending","vin":[],"vout":[{"value":0.5,"n":0,"scriptPubKey":{"addresses":["bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"]}}]},"error":null,"id":1}`))
+		default:
+			w.Write([]byte(`{"result":null,"error":{"code":-5,"message":"No such mempool or blockchain transaction. Use gettransaction for wallet transactions."},"id":1}`))
+		}
+	}))
+	defer server.Close()
+
+	bl := NewBlockchain()
+	if err := bl.Configure(&blockchain.Setting{
+		URI:        server.URL,
+		Currencies: []*currency.Currency{{ID: "BTC", Subunits: 8}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	transactions, err := bl.GetTransaction(context.Background(), "pending")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(transactions))
+	}
+
+	if transactions[0].Status != transaction.StatusPending || transactions[0].FirstSeen.Time.Unix() != 1700000000 {
+		t.Fatalf("expected a pending transaction first seen at 1700000000, got %s at %v", transactions[0].Status, transactions[0].FirstSeen)
+	}
+
+	if !transactions[0].Confirmations.Valid || transactions[0].Confirmations.Int64 != 0 {
+		t.Fatal("expected a pending transaction to have no confirmation")
+	}
+
+	transactions, err = bl.GetTransaction(context.Background(), "mined")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transactions[0].Status != transaction.StatusSucceed || transactions[0].Confirmations.Int64 != 6 {
+		t.Fatalf("expected a transaction with 6 confirmations, got %s with %d", transactions[0].Status, transactions[0].Confirmations.Int64)
+	}
+
+	confirmations, err := bl.GetConfirmations(context.Background(), "mined")
+	if err != nil || confirmations != 6 {
+		t.Fatalf("expected 6 confirmations, got %d (%v)", confirmations, err)
+	}
+
+	_, err = bl.GetTransaction(context.Background(), "dropped")
+	if !errors.Is(err, errors.ErrTransactionNotFound) || errors.Is(err, errors.ErrTransactionDropped) {
+		t.Fatalf("expected a transaction never seen to be not found, got %v", err)
+	}
+
+	if _, err := bl.GetConfirmations(context.Background(), "dropped"); errors.Is(err, errors.ErrTransactionDropped) {
+		t.Fatalf("expected a transaction never seen to be not found, got %v", err)
+	}
+
+	// the transaction was in the mempool before the node forgot it
+	bl.(*Blockchain).pending.FirstSeen("dropped")
+
+	_, err = bl.GetTransaction(context.Background(), "dropped")
+	if !errors.Is(err, errors.ErrTransactionDropped) || !errors.Is(err, errors.ErrTransactionNotFound) {
+		t.Fatalf("expected a dropped transaction, got %v", err)
+	}
+}
+
+func TestBlockchain_Close(t *testing.T) {
+	bl := NewBlockchain()
+	if err := bl.Configure(&blockchain.Setting{
+		URI:        "http://127.0.0.1:8332",
+		Currencies: []*currency.Currency{{ID: "BTC", Subunits: 8}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bl.Health(context.Background()); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+
+	if _, err := bl.GetLatestBlockNumber(context.Background()); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+}
+
+func TestBlockchain_GetBlockByHashWatchlist(t *testing.T) {
+	block := `{"hash":"00ff","height":100,"confirmations":1,"tx":[
+		{"txid":"coinbase","vin":[{"coinbase":"03"}],"vout":[{"value":6.25,"n":0,"scriptPubKey":{"addresses":["miner"]}}]},
+		{"txid":"deposit","vin":[{"txid":"coinbase","vout":0}],"vout":[{"value":1,"n":0,"scriptPubKey":{"addresses":["watched"]}},{"value":5,"n":1,"scriptPubKey":{"addresses":["miner"]}}]},
+		{"txid":"withdraw","vin":[{"txid":"funding","vout":0}],"vout":[{"value":2,"n":0,"scriptPubKey":{"addresses":["someone"]}}]},
+		{"txid":"other","vin":[{"txid":"unrelated","vout":0}],"vout":[{"value":3,"n":0,"scriptPubKey":{"addresses":["someone"]}}]}
+	]}`
+
+	lookups := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch body.Method {
+		case "getblock":
+			w.Write([]byte(`{"result":` + block + `,"error":null,"id":1}`))
+		case "getrawtransaction":
+			txid := body.Params[0].(string)
+			lookups = append(lookups, txid)
+
+			address := "stranger"
+			if txid == "funding" {
+				address = "watched"
+			}
+			w.Write([]byte(`{"result":{"txid":"` + txid + `","vin":[],"vout":[{"value":4,"n":0,"scriptPubKey":{"addresses":["` + address + `"]}}]},"error":null,"id":1}`))
+		default:
+			t.Errorf("unexpected method %s", body.Method)
+		}
+	}))
+	defer server.Close()
+
+	bl := NewBlockchain()
+	if err := bl.Configure(&blockchain.Setting{
+		URI:                  server.URL,
+		Currencies:           []*currency.Currency{{ID: "BTC", Subunits: 8}},
+		WhitelistedAddresses: []string{"watched"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := bl.GetBlockByHash(context.Background(), "00ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the sender of deposit is an output of the same block
+	if len(lookups) != 2 || lookups[0] != "funding" || lookups[1] != "unrelated" {
+		t.Fatalf("expected the spent transactions funding and unrelated to be fetched, got %v", lookups)
+	}
+
+	if len(b.Transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(b.Transactions))
+	}
+
+	if tx := b.Transactions[0]; tx.TxHash.String != "deposit" || tx.FromAddress != "miner" || tx.ToAddress != "watched" {
+		t.Fatalf("unexpected transaction %s from %s to %s", tx.TxHash.String, tx.FromAddress, tx.ToAddress)
+	}
+
+	if tx := b.Transactions[1]; tx.TxHash.String != "withdraw" || tx.FromAddress != "watched" || tx.ToAddress != "someone" {
+		t.Fatalf("unexpected transaction %s from %s to %s", tx.TxHash.String, tx.FromAddress, tx.ToAddress)
+	}
+}
@@ -0,0 +1,67 @@
+package bitcoin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// bitcoind rpc error codes, see src/rpc/protocol.h
+const (
+	rpcWalletInsufficientFunds = -6
+	rpcInvalidAddressOrKey     = -5
+	rpcInvalidParameter        = -8
+	rpcVerifyAlreadyInChain    = -27
+	rpcInWarmup                = -28
+	rpcClientNotConnected      = -9
+	rpcClientInInitialDownload = -10
+)
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("jsonRPC error: %s (code %d)", e.Message, e.Code)
+}
+
+// normalizeError classify an error returned by bitcoind
+func normalizeError(err *rpcError) error {
+	switch err.Code {
+	case rpcWalletInsufficientFunds:
+		return errors.Wrap(errors.ErrInsufficientFunds, err)
+	case rpcInvalidAddressOrKey:
+		switch err.Message {
+		case "Block not found":
+			return errors.Wrap(errors.ErrBlockNotFound, err)
+		case "No such mempool or blockchain transaction. Use gettransaction for wallet transactions.",
+			"No such mempool or blockchain transaction",
+			"No such mempool transaction",
+			"Transaction not in mempool":
+			return errors.Wrap(errors.ErrTransactionNotFound, err)
+		}
+	case rpcInvalidParameter:
+		if err.Message == "Block height out of range" {
+			return errors.Wrap(errors.ErrBlockNotFound, err)
+		}
+	case rpcVerifyAlreadyInChain:
+		return errors.Wrap(errors.ErrAlreadyKnown, err)
+	case rpcInWarmup, rpcClientNotConnected, rpcClientInInitialDownload:
+		return errors.Wrap(errors.ErrNodeUnavailable, err)
+	}
+
+	return err
+}
+
+// normalizeStatusError classify a http error returned without a json-rpc body
+func normalizeStatusError(statusCode int, status string) error {
+	err := fmt.Errorf("jsonRPC error: unexpected http status %s", status)
+
+	if statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests {
+		return errors.Wrap(errors.ErrNodeUnavailable, err)
+	}
+
+	return err
+}
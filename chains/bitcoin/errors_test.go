@@ -0,0 +1,37 @@
+package bitcoin
+
+import (
+	"testing"
+
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+func TestNormalizeError(t *testing.T) {
+	tests := []struct {
+		err  *rpcError
+		kind error
+	}{
+		{&rpcError{Code: -5, Message: "Block not found"}, errors.ErrBlockNotFound},
+		{&rpcError{Code: -8, Message: "Block height out of range"}, errors.ErrBlockNotFound},
+		{&rpcError{Code: -5, Message: "No such mempool or blockchain transaction. Use gettransaction for wallet transactions."}, errors.ErrTransactionNotFound},
+		{&rpcError{Code: -6, Message: "Insufficient funds"}, errors.ErrInsufficientFunds},
+		{&rpcError{Code: -27, Message: "Transaction already in block chain"}, errors.ErrAlreadyKnown},
+		{&rpcError{Code: -28, Message: "Loading block index..."}, errors.ErrNodeUnavailable},
+	}
+
+	for _, test := range tests {
+		err := normalizeError(test.err)
+		if !errors.Is(err, test.kind) {
+			t.Errorf("expected %v to be classified as %v, got %v", test.err, test.kind, err)
+		}
+
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != test.err.Code {
+			t.Errorf("expected %v to keep the original error", test.err)
+		}
+	}
+
+	if !errors.Is(normalizeStatusError(503, "503 Service Unavailable"), errors.ErrNodeUnavailable) {
+		t.Error("expected 503 to be classified as node unavailable")
+	}
+}
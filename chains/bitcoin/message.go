@@ -0,0 +1,267 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+// BIP-137 header offsets of the compact signature by address type
+const (
+	bip137Compressed     = 31
+	bip137P2SHP2WPKH     = 35
+	bip137P2WPKH         = 39
+	bip137HeaderEnd      = 43
+	bip137Uncompressed   = 27
+	compactSignatureSize = 65
+)
+
+// SignMessage sign message with the wallet address key, P2PKH and P2SH-P2WPKH addresses get
+// a BIP-137 signature and P2WPKH addresses a simple BIP-322 one. Keys of HD wallets are derived
+// locally, other wallets ask the node which only signs for legacy addresses
+func (w *Wallet) SignMessage(ctx context.Context, message []byte) (string, error) {
+	index, ok := wallet.ParseIndex(w.wallet.Secret)
+	if !ok || w.hd == nil {
+		var signature string
+		if err := w.jsonRPC(ctx, &signature, "signmessage", w.wallet.Address, string(message)); err != nil {
+			return "", err
+		}
+
+		return signature, nil
+	}
+
+	privateKey, address, err := w.derive(ctx, index)
+	if err != nil {
+		return "", err
+	}
+
+	if len(w.wallet.Address) > 0 && address.EncodeAddress() != w.wallet.Address {
+		return "", fmt.Errorf("wallet secret does not belong to address %s", w.wallet.Address)
+	}
+
+	var signature []byte
+	switch address.(type) {
+	case *btcutil.AddressWitnessPubKeyHash:
+		signature, err = signBIP322(privateKey, address, message)
+	case *btcutil.AddressScriptHash:
+		signature, err = signBIP137(privateKey, message, bip137P2SHP2WPKH)
+	default:
+		signature, err = signBIP137(privateKey, message, bip137Compressed)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyMessage check a BIP-137 or simple BIP-322 signature of message by address
+func (w *Wallet) VerifyMessage(ctx context.Context, address string, message []byte, signature string) (bool, error) {
+	params, err := w.chainParams(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	addr, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		return false, fmt.Errorf("address %s is invalid: %w", address, err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("signature %s is invalid", signature)
+	}
+
+	if len(sig) == compactSignatureSize {
+		return verifyBIP137(addr, message, sig)
+	}
+
+	return verifyBIP322(addr, message, sig)
+}
+
+// bip137Hash is the hash of a message signed by Bitcoin Core signmessage
+func bip137Hash(message []byte) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, "Bitcoin Signed Message:\n")
+	wire.WriteVarBytes(&buf, 0, message)
+
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+func signBIP137(privateKey *btcec.PrivateKey, message []byte, header byte) ([]byte, error) {
+	signature, err := btcec.SignCompact(btcec.S256(), privateKey, bip137Hash(message), true)
+	if err != nil {
+		return nil, err
+	}
+
+	// SignCompact set the header of a compressed P2PKH key
+	signature[0] += header - bip137Compressed
+
+	return signature, nil
+}
+
+func verifyBIP137(address btcutil.Address, message []byte, signature []byte) (bool, error) {
+	header := signature[0]
+	if header < bip137Uncompressed || header >= bip137HeaderEnd {
+		return false, fmt.Errorf("signature header %d is invalid", header)
+	}
+
+	// RecoverCompact only knows the P2PKH headers
+	compact := make([]byte, compactSignatureSize)
+	copy(compact, signature)
+	if header >= bip137P2SHP2WPKH {
+		compact[0] = bip137Compressed + (header-bip137P2SHP2WPKH)%4
+	}
+
+	publicKey, compressed, err := btcec.RecoverCompact(btcec.S256(), compact, bip137Hash(message))
+	if err != nil {
+		return false, nil
+	}
+
+	serialized := publicKey.SerializeUncompressed()
+	if compressed {
+		serialized = publicKey.SerializeCompressed()
+	}
+
+	// the header type is not enforced, some wallets use the P2PKH header for every address
+	pubKeyHash := btcutil.Hash160(serialized)
+	switch addr := address.(type) {
+	case *btcutil.AddressPubKeyHash:
+		return bytes.Equal(addr.ScriptAddress(), pubKeyHash), nil
+	case *btcutil.AddressWitnessPubKeyHash:
+		return compressed && bytes.Equal(addr.ScriptAddress(), pubKeyHash), nil
+	case *btcutil.AddressScriptHash:
+		script, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(pubKeyHash).Script()
+		if err != nil {
+			return false, err
+		}
+
+		return compressed && bytes.Equal(addr.ScriptAddress(), btcutil.Hash160(script)), nil
+	default:
+		return false, fmt.Errorf("BIP-137 signature of %T addresses is not supported", address)
+	}
+}
+
+// bip322Hash is the tagged hash of a BIP-322 message
+func bip322Hash(message []byte) []byte {
+	tag := sha256.Sum256([]byte("BIP0322-signed-message"))
+
+	hash := sha256.New()
+	hash.Write(tag[:])
+	hash.Write(tag[:])
+	hash.Write(message)
+
+	return hash.Sum(nil)
+}
+
+// bip322Transactions build the virtual to_spend and to_sign transactions of a BIP-322 signature
+func bip322Transactions(pkScript []byte, message []byte) (*wire.MsgTx, error) {
+	scriptSig, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(bip322Hash(message)).Script()
+	if err != nil {
+		return nil, err
+	}
+
+	toSpend := wire.NewMsgTx(0)
+	toSpend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+		SignatureScript:  scriptSig,
+		Sequence:         0,
+	})
+	toSpend.AddTxOut(wire.NewTxOut(0, pkScript))
+
+	toSpendHash := toSpend.TxHash()
+
+	toSign := wire.NewMsgTx(0)
+	toSign.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&toSpendHash, 0),
+		Sequence:         0,
+	})
+	toSign.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+
+	return toSign, nil
+}
+
+func signBIP322(privateKey *btcec.PrivateKey, address btcutil.Address, message []byte) ([]byte, error) {
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, err
+	}
+
+	toSign, err := bip322Transactions(pkScript, message)
+	if err != nil {
+		return nil, err
+	}
+
+	witness, err := txscript.WitnessSignature(toSign, txscript.NewTxSigHashes(toSign), 0, 0, pkScript, txscript.SigHashAll, privateKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(witness))); err != nil {
+		return nil, err
+	}
+
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(&buf, 0, item); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func verifyBIP322(address btcutil.Address, message []byte, signature []byte) (bool, error) {
+	if _, ok := address.(*btcutil.AddressWitnessPubKeyHash); !ok {
+		return false, fmt.Errorf("BIP-322 signature of %T addresses is not supported", address)
+	}
+
+	reader := bytes.NewReader(signature)
+	count, err := wire.ReadVarInt(reader, 0)
+	// every witness item takes at least one byte
+	if err != nil || count > uint64(reader.Len()) {
+		return false, fmt.Errorf("signature witness is invalid")
+	}
+
+	witness := make(wire.TxWitness, 0, count)
+	for i := uint64(0); i < count; i++ {
+		item, err := wire.ReadVarBytes(reader, 0, txscript.MaxScriptSize, "witness item")
+		if err != nil {
+			return false, fmt.Errorf("signature witness is invalid: %v", err)
+		}
+
+		witness = append(witness, item)
+	}
+
+	if reader.Len() > 0 {
+		return false, fmt.Errorf("signature has %d trailing bytes", reader.Len())
+	}
+
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return false, err
+	}
+
+	toSign, err := bip322Transactions(pkScript, message)
+	if err != nil {
+		return false, err
+	}
+
+	toSign.TxIn[0].Witness = witness
+
+	engine, err := txscript.NewEngine(pkScript, toSign, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(toSign), 0)
+	if err != nil {
+		return false, err
+	}
+
+	return engine.Execute() == nil, nil
+}
@@ -0,0 +1,139 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+// newMainnetServer fake a mainnet node wallet, the descriptors it imports are appended to imported when not nil
+func newMainnetServer(t *testing.T, imported *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case "getblockchaininfo":
+			w.Write([]byte(`{"result":{"chain":"main"},"error":null,"id":1}`))
+		case "getdescriptorinfo":
+			w.Write([]byte(`{"result":{"checksum":"abcdefgh"},"error":null,"id":1}`))
+		case "importdescriptors":
+			var requests []struct {
+				Desc  string   `json:"desc"`
+				Range []uint32 `json:"range"`
+			}
+			if err := json.Unmarshal(req.Params[0], &requests); err != nil {
+				t.Error(err)
+			}
+
+			for _, request := range requests {
+				if imported != nil {
+					*imported = append(*imported, fmt.Sprintf("%s %v", request.Desc, request.Range))
+				}
+			}
+
+			w.Write([]byte(`{"result":[{"success":true}],"error":null,"id":1}`))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+}
+
+func TestWallet_VerifyMessage(t *testing.T) {
+	server := newMainnetServer(t, nil)
+	defer server.Close()
+
+	w := NewWallet().(*Wallet)
+	if err := w.Configure(&wallet.Setting{
+		Wallet: &wallet.SettingWallet{
+			URI: server.URL,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// test vectors of BIP-322
+	address := "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l"
+	for message, signature := range map[string]string{
+		"":            "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+		"Hello World": "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+	} {
+		ok, err := w.VerifyMessage(context.Background(), address, []byte(message), signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ok {
+			t.Fatalf("expected signature of %q to be valid", message)
+		}
+
+		if ok, _ := w.VerifyMessage(context.Background(), address, []byte(message+"!"), signature); ok {
+			t.Fatalf("expected a tampered message %q to be rejected", message)
+		}
+	}
+}
+
+func TestWallet_SignMessage(t *testing.T) {
+	server := newMainnetServer(t, nil)
+	defer server.Close()
+
+	ctx := context.Background()
+	message := []byte("proof of reserves 2024-01-01")
+
+	for _, path := range []string{"", "m/44'/0'/0'/0", "m/49'/0'/0'/0"} {
+		w := NewWallet().(*Wallet)
+		if err := w.Configure(&wallet.Setting{
+			Wallet: &wallet.SettingWallet{
+				URI: server.URL,
+			},
+			HD: &wallet.HDSetting{
+				Master: "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4",
+				Path:   path,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		address, secret, err := w.CreateAddress(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.wallet.Address = address
+		w.wallet.Secret = secret
+
+		signature, err := w.SignMessage(ctx, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ok, err := w.VerifyMessage(ctx, address, message, signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ok {
+			t.Fatalf("expected signature of %s to be valid", address)
+		}
+
+		other, err := w.DeriveAddress(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, _ := w.VerifyMessage(ctx, other, message, signature); ok {
+			t.Fatalf("expected signature of %s to be rejected for %s", address, other)
+		}
+	}
+}
@@ -3,15 +3,24 @@ package bitcoin
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"math/rand"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/go-resty/resty/v2"
 	"github.com/shopspring/decimal"
 	"github.com/volatiletech/null/v9"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
 	"github.com/zsmartex/multichain/pkg/utils"
 	"github.com/zsmartex/multichain/pkg/wallet"
@@ -21,6 +30,21 @@ type Wallet struct {
 	client   *resty.Client
 	currency *currency.Currency
 	wallet   *wallet.SettingWallet
+	closed   int32 // set by Close, the calls made afterwards fail with ErrClosed
+
+	hd        *wallet.HDSetting
+	nextIndex uint32 // next index CreateAddress derives, see wallet.HDSetting
+
+	mu       sync.Mutex
+	params   *chaincfg.Params // network of the node, nil until checked
+	imported uint32           // HD addresses below this index are imported in the node wallet
+}
+
+// hdImportRange is how many HD addresses are imported in the node wallet at once
+const hdImportRange = 100
+
+func init() {
+	wallet.Register("bitcoin", NewWallet)
 }
 
 func NewWallet() wallet.Wallet {
@@ -29,22 +53,73 @@ func NewWallet() wallet.Wallet {
 	}
 }
 
-func (w *Wallet) Configure(settings *wallet.Setting) {
+func (w *Wallet) Configure(settings *wallet.Setting) error {
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("failed to configure bitcoin wallet: %w", err)
+	}
+
+	if settings.Wallet != nil {
+		if err := validateURI(settings.Wallet.URI); err != nil {
+			return fmt.Errorf("failed to configure bitcoin wallet: %w", err)
+		}
+	}
+
+	if settings.HD != nil {
+		w.hd = settings.HD
+		atomic.StoreUint32(&w.nextIndex, settings.HD.NextIndex)
+
+		w.mu.Lock()
+		w.imported = 0
+		w.mu.Unlock()
+	}
+
 	if settings.Wallet != nil {
 		w.wallet = settings.Wallet
+
+		w.mu.Lock()
+		w.params = nil
+		w.imported = 0
+		w.mu.Unlock()
 	}
 
 	if settings.Currency != nil {
 		w.currency = settings.Currency
 	}
+
+	atomic.StoreInt32(&w.closed, 0)
+
+	return nil
+}
+
+func (w *Wallet) Health(ctx context.Context) error {
+	if w.wallet == nil {
+		return fmt.Errorf("%w: bitcoin wallet is not configured", errors.ErrInvalidConfiguration)
+	}
+
+	var resp struct {
+		WalletName string `json:"walletname"`
+	}
+
+	return w.jsonRPC(ctx, &resp, "getwalletinfo")
+}
+
+func (w *Wallet) Close() error {
+	atomic.StoreInt32(&w.closed, 1)
+	w.client.GetClient().CloseIdleConnections()
+
+	return nil
 }
 
 func (w *Wallet) jsonRPC(ctx context.Context, resp interface{}, method string, params ...interface{}) error {
+	if atomic.LoadInt32(&w.closed) == 1 {
+		return errors.ErrClosed
+	}
+
 	type Result struct {
 		Version string           `json:"version"`
 		ID      int              `json:"id"`
 		Result  *json.RawMessage `json:"result"`
-		Error   *json.RawMessage `json:"error"`
+		Error   *rpcError        `json:"error"`
 	}
 
 	uri, err := url.Parse(w.wallet.URI)
@@ -56,6 +131,7 @@ func (w *Wallet) jsonRPC(ctx context.Context, resp interface{}, method string, p
 		R().
 		SetContext(ctx).
 		SetResult(Result{}).
+		SetError(Result{}).
 		SetHeaders(map[string]string{
 			"Accept":       "application/json",
 			"Content-Type": "application/json",
@@ -69,13 +145,22 @@ func (w *Wallet) jsonRPC(ctx context.Context, resp interface{}, method string, p
 		Post(uri.JoinPath(method).String())
 
 	if err != nil {
-		return err
+		return errors.Wrap(errors.ErrNodeUnavailable, err)
 	}
 
-	result := response.Result().(*Result)
+	var result *Result
+	if response.IsError() {
+		result = response.Error().(*Result)
+	} else {
+		result = response.Result().(*Result)
+	}
 
 	if result.Error != nil {
-		return errors.New("jsonRPC error: " + string(*result.Error))
+		return normalizeError(result.Error)
+	}
+
+	if response.IsError() {
+		return normalizeStatusError(response.StatusCode(), response.Status())
 	}
 
 	if result.Result == nil {
@@ -89,7 +174,25 @@ func (w *Wallet) jsonRPC(ctx context.Context, resp interface{}, method string, p
 	return nil
 }
 
+// CreateAddress ask the node for a new address, or derive the next one when the wallet is HD.
+// HD addresses are imported in the node wallet with their descriptor so sendtoaddress can spend
+// them, addresses restored from NextIndex must be rescanned by the node if it never had them
 func (w *Wallet) CreateAddress(ctx context.Context) (address, secret string, err error) {
+	if w.hd != nil {
+		index := atomic.AddUint32(&w.nextIndex, 1) - 1
+
+		address, err := w.DeriveAddress(ctx, index)
+		if err != nil {
+			return "", "", err
+		}
+
+		if err := w.importDescriptor(ctx, index); err != nil {
+			return "", "", err
+		}
+
+		return address, strconv.FormatUint(uint64(index), 10), nil
+	}
+
 	secret = utils.RandomString(32)
 
 	err = w.jsonRPC(ctx, &address, "getnewaddress", secret)
@@ -97,6 +200,191 @@ func (w *Wallet) CreateAddress(ctx context.Context) (address, secret string, err
 	return
 }
 
+// DeriveAddress return the address at index of the HD wallet, its type follows the purpose
+// of the path: P2PKH for 44, P2SH-P2WPKH for 49 and P2WPKH for 84 which is the default
+func (w *Wallet) DeriveAddress(ctx context.Context, index uint32) (string, error) {
+	_, address, err := w.derive(ctx, index)
+	if err != nil {
+		return "", err
+	}
+
+	return address.EncodeAddress(), nil
+}
+
+// derive return the key and address at index of the HD wallet
+func (w *Wallet) derive(ctx context.Context, index uint32) (*btcec.PrivateKey, btcutil.Address, error) {
+	if w.hd == nil {
+		return nil, nil, fmt.Errorf("%w: bitcoin wallet is not hd", errors.ErrInvalidConfiguration)
+	}
+
+	params, err := w.chainParams(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path, purpose, err := w.hdPath(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := w.hd.DeriveKey(path, index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubKeyHash := btcutil.Hash160((*btcec.PublicKey)(&privateKey.PublicKey).SerializeCompressed())
+
+	var address btcutil.Address
+	switch purpose {
+	case 44:
+		address, err = btcutil.NewAddressPubKeyHash(pubKeyHash, params)
+	case 49:
+		var script []byte
+		if script, err = txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(pubKeyHash).Script(); err == nil {
+			address, err = btcutil.NewAddressScriptHash(script, params)
+		}
+	case 84:
+		address, err = btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, params)
+	default:
+		return nil, nil, fmt.Errorf("%w: hd path %s has an unsupported purpose", errors.ErrInvalidConfiguration, path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return (*btcec.PrivateKey)(privateKey), address, nil
+}
+
+// hdPath return the derivation path of the HD addresses on the network of params and its purpose
+func (w *Wallet) hdPath(params *chaincfg.Params) (string, uint32, error) {
+	var coinType uint32
+	if params.Net != chaincfg.MainNetParams.Net {
+		coinType = 1
+	}
+
+	path := w.hd.DerivationPath(84, coinType)
+	indexes, err := wallet.ParsePath(path)
+	if err != nil || len(indexes) == 0 {
+		return "", 0, fmt.Errorf("%w: hd path %s has no purpose", errors.ErrInvalidConfiguration, path)
+	}
+
+	return path, indexes[0] - hdkeychain.HardenedKeyStart, nil
+}
+
+// importDescriptor import the HD addresses up to index in the node wallet, hdImportRange
+// addresses are imported at once so the node is only called every hdImportRange addresses
+func (w *Wallet) importDescriptor(ctx context.Context, index uint32) error {
+	w.mu.Lock()
+	imported := w.imported
+	w.mu.Unlock()
+
+	if index < imported {
+		return nil
+	}
+
+	params, err := w.chainParams(ctx)
+	if err != nil {
+		return err
+	}
+
+	path, purpose, err := w.hdPath(params)
+	if err != nil {
+		return err
+	}
+
+	master, err := w.hd.ExtendedMasterKey(params)
+	if err != nil {
+		return err
+	}
+
+	key := master + strings.TrimPrefix(path, "m") + "/*"
+
+	var descriptor string
+	switch purpose {
+	case 44:
+		descriptor = fmt.Sprintf("pkh(%s)", key)
+	case 49:
+		descriptor = fmt.Sprintf("sh(wpkh(%s))", key)
+	case 84:
+		descriptor = fmt.Sprintf("wpkh(%s)", key)
+	default:
+		return fmt.Errorf("%w: hd path %s has an unsupported purpose", errors.ErrInvalidConfiguration, path)
+	}
+
+	var info struct {
+		Checksum string `json:"checksum"`
+	}
+	if err := w.jsonRPC(ctx, &info, "getdescriptorinfo", descriptor); err != nil {
+		return err
+	}
+
+	end := index + hdImportRange
+	var resp []struct {
+		Success bool      `json:"success"`
+		Error   *rpcError `json:"error"`
+	}
+	if err := w.jsonRPC(ctx, &resp, "importdescriptors", []map[string]interface{}{
+		{
+			"desc":      descriptor + "#" + info.Checksum,
+			"timestamp": "now",
+			"range":     []uint32{0, end - 1},
+			"active":    false,
+			"internal":  false,
+		},
+	}); err != nil {
+		return err
+	}
+
+	if len(resp) != 1 || !resp[0].Success {
+		if len(resp) == 1 && resp[0].Error != nil {
+			return fmt.Errorf("failed to import hd descriptor: %w", normalizeError(resp[0].Error))
+		}
+
+		return errors.New("failed to import hd descriptor")
+	}
+
+	w.mu.Lock()
+	if end > w.imported {
+		w.imported = end
+	}
+	w.mu.Unlock()
+
+	return nil
+}
+
+// chainParams return the network of the node, it is checked once
+func (w *Wallet) chainParams(ctx context.Context) (*chaincfg.Params, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.params != nil {
+		return w.params, nil
+	}
+
+	var resp struct {
+		Chain string `json:"chain"`
+	}
+
+	if err := w.jsonRPC(ctx, &resp, "getblockchaininfo"); err != nil {
+		return nil, err
+	}
+
+	switch resp.Chain {
+	case "main":
+		w.params = &chaincfg.MainNetParams
+	case "test":
+		w.params = &chaincfg.TestNet3Params
+	case "regtest":
+		w.params = &chaincfg.RegressionNetParams
+	case "signet":
+		w.params = &chaincfg.SigNetParams
+	default:
+		return nil, fmt.Errorf("unknown bitcoin chain %s", resp.Chain)
+	}
+
+	return w.params, nil
+}
+
 func (w *Wallet) CreateTransaction(ctx context.Context, tx *transaction.Transaction, options map[string]interface{}) (*transaction.Transaction, error) {
 	var txid string
 	var subtractFee bool
@@ -143,5 +431,5 @@ func (w *Wallet) LoadBalance(ctx context.Context) (balance decimal.Decimal, err
 }
 
 func (w *Wallet) PrepareDepositCollection(context.Context, *transaction.Transaction, []*transaction.Transaction, *currency.Currency) (*transaction.Transaction, error) {
-	return nil, errors.New("failed to prepare deposit collection due: bitcoin client are not supported")
+	return nil, fmt.Errorf("%w: bitcoin deposits need no collection fee", errors.ErrNotSupported)
 }
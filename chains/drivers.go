@@ -0,0 +1,9 @@
+package chains
+
+// Import this package to register all of the built-in chain drivers
+// with the blockchain and wallet registries.
+import (
+	_ "github.com/zsmartex/multichain/chains/bitcoin"
+	_ "github.com/zsmartex/multichain/chains/evm"
+	_ "github.com/zsmartex/multichain/chains/tron"
+)
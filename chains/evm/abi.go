@@ -0,0 +1,252 @@
+package evm
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// ABIOption is the currency option holding the json ABI of a non-standard token contract
+const ABIOption = "abi"
+
+const erc20ABIDefinition = `[{"constant":true,"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"totalSupply","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"},{"constant":true,"inputs":[{"name":"_owner","type":"address"},{"name":"_spender","type":"address"}],"name":"allowance","outputs":[{"name":"remaining","type":"uint256"}],"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transfer","outputs":[{"name":"success","type":"bool"}],"stateMutability":"nonpayable","type":"function"},{"constant":false,"inputs":[{"name":"_from","type":"address"},{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transferFrom","outputs":[{"name":"success","type":"bool"}],"stateMutability":"nonpayable","type":"function"},{"constant":false,"inputs":[{"name":"_spender","type":"address"},{"name":"_value","type":"uint256"}],"name":"approve","outputs":[{"name":"success","type":"bool"}],"stateMutability":"nonpayable","type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":true,"name":"_to","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Transfer","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"name":"_owner","type":"address"},{"indexed":true,"name":"_spender","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Approval","type":"event"}]`
+
+// erc20ABI is used for every token without a registered ABI
+var erc20ABI = mustParseABI(erc20ABIDefinition)
+
+var ErrUnknownEvent = errors.New("unknown event")
+
+// ABIRegistry hold the parsed ABIs of the contracts by contract address and currency id,
+// each definition is parsed once no matter how many contracts use it. Every blockchain and
+// wallet has its own registry unless they are given a shared one
+type ABIRegistry struct {
+	mu         sync.RWMutex
+	parsed     map[[sha256.Size]byte]*abi.ABI
+	byContract map[common.Address]*abi.ABI
+	byCurrency map[string]*abi.ABI
+	currencies map[common.Address]string // currency id of the token contracts
+}
+
+// Event is a log decoded with the ABI of the contract which emitted it
+type Event struct {
+	Address  common.Address
+	Name     string
+	TxHash   common.Hash
+	LogIndex uint
+	Values   map[string]interface{}
+}
+
+func NewABIRegistry() *ABIRegistry {
+	return &ABIRegistry{
+		parsed:     make(map[[sha256.Size]byte]*abi.ABI),
+		byContract: make(map[common.Address]*abi.ABI),
+		byCurrency: make(map[string]*abi.ABI),
+		currencies: make(map[common.Address]string),
+	}
+}
+
+// Register set the ABI of a contract, it takes precedence over the currency ABIs
+func (r *ABIRegistry) Register(contractAddress string, definition string) error {
+	if !common.IsHexAddress(contractAddress) {
+		return fmt.Errorf("contract address %s is invalid", contractAddress)
+	}
+
+	parsed, err := r.parse(definition)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.byContract[common.HexToAddress(contractAddress)] = parsed
+
+	return nil
+}
+
+// RegisterCurrency set the ABI of a currency from its abi option, currencies without one use the ERC20 ABI
+func (r *ABIRegistry) RegisterCurrency(c *currency.Currency) error {
+	parsed, err := r.parseCurrency(c)
+	if err != nil {
+		return err
+	}
+
+	r.setCurrency(c, parsed)
+
+	return nil
+}
+
+// parseCurrency parse the abi option of a currency, it is nil when the currency has none
+func (r *ABIRegistry) parseCurrency(c *currency.Currency) (*abi.ABI, error) {
+	option, ok := c.Options[ABIOption]
+	if !ok || option == nil {
+		return nil, nil
+	}
+
+	definition, ok := option.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: currency %s %s must be a string", errors.ErrInvalidConfiguration, c.ID, ABIOption)
+	}
+
+	parsed, err := r.parse(definition)
+	if err != nil {
+		return nil, fmt.Errorf("%w: currency %s %s is invalid: %v", errors.ErrInvalidConfiguration, c.ID, ABIOption, err)
+	}
+
+	return parsed, nil
+}
+
+// setCurrency replace the ABI of a currency, a nil ABI removes the one previously set
+func (r *ABIRegistry) setCurrency(c *currency.Currency, parsed *abi.ABI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for address, id := range r.currencies {
+		if id == c.ID {
+			delete(r.currencies, address)
+		}
+	}
+
+	if parsed == nil {
+		delete(r.byCurrency, c.ID)
+		return
+	}
+
+	r.byCurrency[c.ID] = parsed
+	if contract, _ := contractOf(c); common.IsHexAddress(contract) {
+		r.currencies[common.HexToAddress(contract)] = c.ID
+	}
+}
+
+// ABI return the ABI of the currency contract, looked up by contract address then currency id
+func (r *ABIRegistry) ABI(c *currency.Currency) *abi.ABI {
+	var address common.Address
+	if contract, _ := contractOf(c); common.IsHexAddress(contract) {
+		address = common.HexToAddress(contract)
+	}
+
+	return r.lookup(address, c.ID)
+}
+
+// DecodeEvent decode a log with the ABI registered for its contract or the ERC20 ABI
+func (r *ABIRegistry) DecodeEvent(log *types.Log) (*Event, error) {
+	if len(log.Topics) == 0 {
+		return nil, fmt.Errorf("%w: log %d of %s is anonymous", ErrUnknownEvent, log.Index, log.TxHash.Hex())
+	}
+
+	parsed := r.lookup(log.Address, "")
+
+	event, err := parsed.EventByID(log.Topics[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s emitted by %s", ErrUnknownEvent, log.Topics[0].Hex(), log.Address.Hex())
+	}
+
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+
+	if len(indexed) != len(log.Topics)-1 {
+		return nil, fmt.Errorf("%w: %s emitted by %s has %d topics", ErrUnknownEvent, event.Name, log.Address.Hex(), len(log.Topics))
+	}
+
+	values := make(map[string]interface{}, len(event.Inputs))
+	if err := event.Inputs.UnpackIntoMap(values, log.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode %s data: %v", event.Name, err)
+	}
+
+	if err := abi.ParseTopicsIntoMap(values, indexed, log.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("failed to decode %s topics: %v", event.Name, err)
+	}
+
+	return &Event{
+		Address:  log.Address,
+		Name:     event.Name,
+		TxHash:   log.TxHash,
+		LogIndex: log.Index,
+		Values:   values,
+	}, nil
+}
+
+// GetEvents decode the logs of a transaction, logs without a known event are skipped
+func (b *Blockchain) GetEvents(ctx context.Context, txHash string) ([]*Event, error) {
+	receipt, err := b.getReceipt(ctx, common.HexToHash(txHash))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0, len(receipt.Logs))
+	for _, l := range receipt.Logs {
+		event, err := b.abis.DecodeEvent(l)
+		if errors.Is(err, ErrUnknownEvent) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (r *ABIRegistry) lookup(address common.Address, currencyID string) *abi.ABI {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if parsed, ok := r.byContract[address]; ok {
+		return parsed
+	}
+
+	if len(currencyID) == 0 {
+		currencyID = r.currencies[address]
+	}
+
+	if parsed, ok := r.byCurrency[currencyID]; ok {
+		return parsed
+	}
+
+	return &erc20ABI
+}
+
+func (r *ABIRegistry) parse(definition string) (*abi.ABI, error) {
+	key := sha256.Sum256([]byte(definition))
+
+	r.mu.RLock()
+	parsed, ok := r.parsed[key]
+	r.mu.RUnlock()
+	if ok {
+		return parsed, nil
+	}
+
+	result, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.parsed[key] = &result
+
+	return &result, nil
+}
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
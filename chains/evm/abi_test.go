@@ -0,0 +1,155 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zsmartex/multichain/pkg/blockchain"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// a token whose transfer returns nothing, with a custom deposit event
+const customTokenABI = `[{"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transfer","outputs":[],"stateMutability":"nonpayable","type":"function"},{"anonymous":false,"inputs":[{"indexed":true,"name":"account","type":"address"},{"indexed":false,"name":"amount","type":"uint256"},{"indexed":false,"name":"memo","type":"bytes32"}],"name":"Deposit","type":"event"}]`
+
+func TestABIRegistry_Currency(t *testing.T) {
+	contract := "0x1111111111111111111111111111111111111111"
+	usdt := &currency.Currency{ID: "usdt", Options: map[string]interface{}{"erc20_contract_address": contract, ABIOption: customTokenABI}}
+	usdc := &currency.Currency{ID: "usdc", Options: map[string]interface{}{"erc20_contract_address": "0x2222222222222222222222222222222222222222", ABIOption: customTokenABI}}
+	dai := &currency.Currency{ID: "dai", Options: map[string]interface{}{"erc20_contract_address": "0x3333333333333333333333333333333333333333"}}
+
+	r := NewABIRegistry()
+	for _, c := range []*currency.Currency{usdt, usdc, dai} {
+		if err := r.RegisterCurrency(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if r.ABI(usdt) != r.ABI(usdc) {
+		t.Fatal("expected the same definition to be parsed once")
+	}
+
+	if r.ABI(dai) != &erc20ABI {
+		t.Fatal("expected the erc20 abi for a currency without abi option")
+	}
+
+	if method := r.ABI(usdt).Methods["transfer"]; len(method.Outputs) != 0 {
+		t.Fatalf("expected the registered transfer without outputs, got %v", method.Outputs)
+	}
+
+	if _, ok := r.ABI(usdt).Methods["balanceOf"]; ok {
+		t.Fatal("expected the registered abi instead of the erc20 one")
+	}
+
+	invalid := &currency.Currency{ID: "bad", Options: map[string]interface{}{ABIOption: "[{"}}
+	if err := r.RegisterCurrency(invalid); !errors.Is(err, errors.ErrInvalidConfiguration) {
+		t.Fatalf("expected invalid configuration, got %v", err)
+	}
+
+	// a currency registered again without abi option goes back to the erc20 abi
+	if err := r.RegisterCurrency(&currency.Currency{ID: "usdt", Options: map[string]interface{}{"erc20_contract_address": contract}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.ABI(usdt) != &erc20ABI || r.lookup(common.HexToAddress(contract), "") != &erc20ABI {
+		t.Fatal("expected the previous abi to be removed")
+	}
+}
+
+func TestBlockchain_ConfigureABI(t *testing.T) {
+	eth := &currency.Currency{ID: "eth", Subunits: 18}
+	custom := &currency.Currency{ID: "usdt", Subunits: 6, Options: map[string]interface{}{"erc20_contract_address": "0x1111111111111111111111111111111111111111", ABIOption: customTokenABI}}
+	standard := &currency.Currency{ID: "usdt", Subunits: 6, Options: map[string]interface{}{"erc20_contract_address": "0x1111111111111111111111111111111111111111"}}
+
+	configure := func(b *Blockchain, options map[string]interface{}, currencies ...*currency.Currency) error {
+		return b.Configure(&blockchain.Setting{URI: "http://127.0.0.1:8545", Currencies: currencies, Options: options})
+	}
+
+	b := NewBlockchain().(*Blockchain)
+	other := NewBlockchain().(*Blockchain)
+	if err := configure(b, nil, eth, custom); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := configure(other, nil, eth, standard); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.abis.ABI(custom) == &erc20ABI || other.abis.ABI(standard) != &erc20ABI {
+		t.Fatal("expected each blockchain to keep its own abis")
+	}
+
+	// a configuration which fails its checks registers nothing
+	if err := configure(other, map[string]interface{}{"scan_mode": "unknown"}, eth, custom); !errors.Is(err, errors.ErrInvalidConfiguration) {
+		t.Fatalf("expected invalid configuration, got %v", err)
+	}
+
+	if other.abis.ABI(custom) != &erc20ABI {
+		t.Fatal("expected the abi of a rejected configuration not to be registered")
+	}
+
+	if err := configure(b, nil, eth, standard); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.abis.ABI(standard) != &erc20ABI {
+		t.Fatal("expected the abi to be removed when the option is")
+	}
+}
+
+func TestABIRegistry_DecodeEvent(t *testing.T) {
+	contract := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	account := common.HexToAddress("0x4444444444444444444444444444444444444444")
+
+	r := NewABIRegistry()
+	if err := r.Register(contract.Hex(), customTokenABI); err != nil {
+		t.Fatal(err)
+	}
+
+	memo := common.HexToHash("0x01")
+	deposit := &types.Log{
+		Address: contract,
+		Topics:  []common.Hash{crypto.Keccak256Hash([]byte("Deposit(address,uint256,bytes32)")), common.BytesToHash(account.Bytes())},
+		Data:    append(common.LeftPadBytes(big.NewInt(42).Bytes(), 32), memo.Bytes()...),
+	}
+
+	event, err := r.DecodeEvent(deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Name != "Deposit" || event.Values["account"] != account || event.Values["amount"].(*big.Int).Int64() != 42 || event.Values["memo"] != [32]byte(memo) {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	// contracts without a registered abi are decoded as erc20 tokens
+	transfer := &types.Log{
+		Address: common.HexToAddress("0x5555555555555555555555555555555555555555"),
+		Topics:  []common.Hash{common.HexToHash(tokenEventIdentifier), common.BytesToHash(account.Bytes()), common.BytesToHash(contract.Bytes())},
+		Data:    common.LeftPadBytes(big.NewInt(7).Bytes(), 32),
+	}
+
+	event, err = r.DecodeEvent(transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Name != "Transfer" || event.Values["_from"] != account || event.Values["_to"] != contract || event.Values["_value"].(*big.Int).Int64() != 7 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	// erc721 transfers share the erc20 topic but index the token id
+	transfer.Topics = append(transfer.Topics, common.BigToHash(big.NewInt(1)))
+	transfer.Data = nil
+	if _, err := r.DecodeEvent(transfer); !errors.Is(err, ErrUnknownEvent) {
+		t.Fatalf("expected unknown event, got %v", err)
+	}
+
+	deposit.Topics[0] = common.HexToHash("0x01")
+	if _, err := r.DecodeEvent(deposit); !errors.Is(err, ErrUnknownEvent) {
+		t.Fatalf("expected unknown event, got %v", err)
+	}
+}
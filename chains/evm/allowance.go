@@ -0,0 +1,300 @@
+package evm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/shopspring/decimal"
+	"github.com/volatiletech/null/v9"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+// erc2612ABI is the permit extension of ERC20 tokens
+var erc2612ABI = mustParseABI(`[{"inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"},{"name":"value","type":"uint256"},{"name":"deadline","type":"uint256"},{"name":"v","type":"uint8"},{"name":"r","type":"bytes32"},{"name":"s","type":"bytes32"}],"name":"permit","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"owner","type":"address"}],"name":"nonces","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"DOMAIN_SEPARATOR","outputs":[{"name":"","type":"bytes32"}],"stateMutability":"view","type":"function"}]`)
+
+var ErrPermitNotSupported = errors.New("token does not support permit")
+
+// Permit is an EIP-2612 approval signed by the owner, anyone can submit it with Wallet.Permit
+type Permit struct {
+	Owner    common.Address
+	Spender  common.Address
+	Value    *big.Int
+	Nonce    *big.Int
+	Deadline *big.Int
+	V        uint8
+	R        [32]byte
+	S        [32]byte
+}
+
+// Allowance return the amount spender may transfer from owner with TransferFrom
+func (w *Wallet) Allowance(ctx context.Context, owner, spender string) (decimal.Decimal, error) {
+	data, err := w.abis.ABI(w.currency).Pack("allowance", common.HexToAddress(w.normalizeAddress(owner)), common.HexToAddress(w.normalizeAddress(spender)))
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	result, err := w.callToken(ctx, data)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if len(result) != 32 {
+		return decimal.Zero, fmt.Errorf("unexpected allowance result length %d", len(result))
+	}
+
+	return decimal.NewFromBigInt(new(big.Int).SetBytes(result), -w.currency.Subunits), nil
+}
+
+// Approve let spender transfer up to amount from the wallet address
+func (w *Wallet) Approve(ctx context.Context, spender string, amount decimal.Decimal, options map[string]interface{}) (*transaction.Transaction, error) {
+	spenderAddress := common.HexToAddress(w.normalizeAddress(spender))
+
+	data, err := w.abis.ABI(w.currency).Pack("approve", spenderAddress, w.ConvertToBaseUnit(amount).BigInt())
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, cost, err := w.sendTokenCall(ctx, data, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.tokenCallTransaction(w.wallet.Address, spenderAddress.Hex(), amount, signedTx, cost), nil
+}
+
+// TransferFrom transfer tx.Amount from tx.FromAddress to tx.ToAddress, the wallet address
+// must have an allowance of tx.FromAddress and pays the fee
+func (w *Wallet) TransferFrom(ctx context.Context, tx *transaction.Transaction, options map[string]interface{}) (*transaction.Transaction, error) {
+	from := common.HexToAddress(w.normalizeAddress(tx.FromAddress))
+	to := common.HexToAddress(w.normalizeAddress(tx.ToAddress))
+
+	data, err := w.abis.ABI(w.currency).Pack("transferFrom", from, to, w.ConvertToBaseUnit(tx.Amount).BigInt())
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, cost, err := w.sendTokenCall(ctx, data, options)
+	if err != nil {
+		return nil, err
+	}
+
+	result := w.tokenCallTransaction(tx.FromAddress, tx.ToAddress, tx.Amount, signedTx, cost)
+	tx.Fee = result.Fee
+	tx.CurrencyFee = result.CurrencyFee
+	tx.Status = result.Status
+	tx.TxHash = result.TxHash
+
+	return tx, nil
+}
+
+// SignPermit sign offline an EIP-2612 permit letting spender transfer up to amount from the wallet address
+// until deadline, the EIP-712 domain version is "1" unless the currency has a permit_version option
+func (w *Wallet) SignPermit(ctx context.Context, spender string, amount decimal.Decimal, deadline time.Time) (*Permit, error) {
+	privateKey, err := w.privateKey(w.wallet.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	chainID, err := w.client.ChainID(ctx)
+	if err != nil {
+		return nil, normalizeError(err, nil)
+	}
+
+	domainSeparator, err := w.callToken(ctx, erc2612ABI.Methods["DOMAIN_SEPARATOR"].ID)
+	if err != nil || len(domainSeparator) != 32 {
+		return nil, fmt.Errorf("%w: %s has no DOMAIN_SEPARATOR", ErrPermitNotSupported, w.currency.ID)
+	}
+
+	name, err := w.callToken(ctx, nameMethodID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenName, err := decodeTokenString(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode name of %s: %v", w.currency.ID, err)
+	}
+
+	data, err := erc2612ABI.Pack("nonces", owner)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := w.callToken(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	version := "1"
+	if v, ok := w.currency.Options["permit_version"].(string); ok {
+		version = v
+	}
+
+	permit := &Permit{
+		Owner:    owner,
+		Spender:  common.HexToAddress(w.normalizeAddress(spender)),
+		Value:    w.ConvertToBaseUnit(amount).BigInt(),
+		Nonce:    new(big.Int).SetBytes(nonce),
+		Deadline: big.NewInt(deadline.Unix()),
+	}
+
+	typedData := permitTypedData(tokenName, version, chainID, common.HexToAddress(w.normalizeAddress(w.ContractAddress())), permit)
+
+	// a mismatch means the token does not follow EIP-2612 and the permit would be rejected
+	computed, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(computed, domainSeparator) {
+		return nil, fmt.Errorf("%w: %s DOMAIN_SEPARATOR does not match name %q and version %q", ErrPermitNotSupported, w.currency.ID, tokenName, version)
+	}
+
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := crypto.Sign(digest, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	copy(permit.R[:], signature[:32])
+	copy(permit.S[:], signature[32:64])
+	permit.V = signature[64] + 27
+
+	return permit, nil
+}
+
+// Permit submit a permit signed by its owner, the wallet pays the fee and spender can then use TransferFrom
+func (w *Wallet) Permit(ctx context.Context, permit *Permit, options map[string]interface{}) (*transaction.Transaction, error) {
+	if permit.Deadline.Cmp(big.NewInt(time.Now().Unix())) < 0 {
+		return nil, fmt.Errorf("permit of %s expired at %s", permit.Owner.Hex(), time.Unix(permit.Deadline.Int64(), 0))
+	}
+
+	data, err := erc2612ABI.Pack("permit", permit.Owner, permit.Spender, permit.Value, permit.Deadline, permit.V, permit.R, permit.S)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, cost, err := w.sendTokenCall(ctx, data, options)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := decimal.NewFromBigInt(permit.Value, -w.currency.Subunits)
+
+	return w.tokenCallTransaction(permit.Owner.Hex(), permit.Spender.Hex(), amount, signedTx, cost), nil
+}
+
+func permitTypedData(name, version string, chainID *big.Int, contract common.Address, permit *Permit) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Permit": {
+				{Name: "owner", Type: "address"},
+				{Name: "spender", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Permit",
+		Domain: apitypes.TypedDataDomain{
+			Name:              name,
+			Version:           version,
+			ChainId:           (*math.HexOrDecimal256)(chainID),
+			VerifyingContract: contract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"owner":    permit.Owner.Hex(),
+			"spender":  permit.Spender.Hex(),
+			"value":    permit.Value.String(),
+			"nonce":    permit.Nonce.String(),
+			"deadline": permit.Deadline.String(),
+		},
+	}
+}
+
+// callToken call the token contract of the wallet currency at the latest block
+func (w *Wallet) callToken(ctx context.Context, data []byte) ([]byte, error) {
+	contract := w.ContractAddress()
+	if len(contract) == 0 {
+		return nil, fmt.Errorf("currency %s is not an erc20 token", w.currency.ID)
+	}
+
+	contractAddress := common.HexToAddress(w.normalizeAddress(contract))
+	result, err := w.client.CallContract(ctx, ethereum.CallMsg{
+		To:   &contractAddress,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, normalizeError(err, nil)
+	}
+
+	return result, nil
+}
+
+// sendTokenCall send data to the token contract of the wallet currency and return the fee it may cost
+func (w *Wallet) sendTokenCall(ctx context.Context, data []byte, opt map[string]interface{}) (*types.Transaction, *big.Int, error) {
+	contract := w.ContractAddress()
+	if len(contract) == 0 {
+		return nil, nil, fmt.Errorf("currency %s is not an erc20 token", w.currency.ID)
+	}
+
+	options := w.mergeOptions(defaultErc20Fee, w.currency.Options, opt)
+
+	fee, err := w.suggestFee(ctx, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contractAddress := common.HexToAddress(w.normalizeAddress(contract))
+	gasLimit, err := w.gasLimit(ctx, options, ethereum.CallMsg{
+		From: common.HexToAddress(w.normalizeAddress(w.wallet.Address)),
+		To:   &contractAddress,
+		Data: data,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signedTx, err := w.sendTransaction(ctx, contractAddress, nil, data, gasLimit, fee)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return signedTx, fee.Cost(gasLimit), nil
+}
+
+func (w *Wallet) tokenCallTransaction(from, to string, amount decimal.Decimal, signedTx *types.Transaction, cost *big.Int) *transaction.Transaction {
+	tx := &transaction.Transaction{
+		Currency:    w.currency.ID,
+		FromAddress: from,
+		ToAddress:   to,
+		Amount:      amount,
+		TxHash:      null.StringFrom(signedTx.Hash().Hex()),
+		Status:      transaction.StatusPending,
+	}
+	w.setFee(tx, cost)
+
+	return tx
+}
@@ -0,0 +1,142 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+// newPermitServer fake a node with a permit token whose domain separator is computed by the spec
+func newPermitServer(t *testing.T, contract common.Address, version string) (*httptest.Server, []byte) {
+	word := func(b []byte) []byte { return common.LeftPadBytes(b, 32) }
+
+	domainSeparator := crypto.Keccak256(
+		crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)")),
+		crypto.Keccak256([]byte("Tether USD")),
+		crypto.Keccak256([]byte(version)),
+		word(big.NewInt(1).Bytes()),
+		word(contract.Bytes()),
+	)
+
+	name, err := mustParseABI(`[{"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"type":"function"}]`).Methods["name"].Outputs.Pack("Tether USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+
+		switch msg.Method {
+		case "eth_chainId":
+			msg.Result = "0x1"
+		case "eth_call":
+			var args callArgs
+			json.Unmarshal(msg.Params[0], &args)
+
+			switch common.Bytes2Hex(args.Data[:4]) {
+			case common.Bytes2Hex(erc2612ABI.Methods["DOMAIN_SEPARATOR"].ID):
+				msg.Result = hexutil.Bytes(domainSeparator)
+			case common.Bytes2Hex(nameMethodID):
+				msg.Result = hexutil.Bytes(name)
+			case common.Bytes2Hex(erc2612ABI.Methods["nonces"].ID):
+				msg.Result = hexutil.Bytes(word([]byte{3}))
+			default:
+				t.Errorf("unexpected call %x", args.Data)
+			}
+		default:
+			t.Errorf("unexpected method %s", msg.Method)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": msg.Result})
+	}))
+
+	return server, domainSeparator
+}
+
+func TestWallet_SignPermit(t *testing.T) {
+	contract := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	spender := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	owner := common.HexToAddress("0xD2E03d98cd8af2D84522Cf11D471AaA4ca60D8CA")
+
+	for _, test := range []struct {
+		tokenVersion string
+		option       interface{}
+		expected     error
+	}{
+		{tokenVersion: "1"},
+		{tokenVersion: "2", expected: ErrPermitNotSupported},
+		{tokenVersion: "2", option: "2"},
+	} {
+		server, domainSeparator := newPermitServer(t, contract, test.tokenVersion)
+		defer server.Close()
+
+		options := map[string]interface{}{"erc20_contract_address": contract.Hex()}
+		if test.option != nil {
+			options["permit_version"] = test.option
+		}
+
+		w := NewWallet().(*Wallet)
+		if err := w.Configure(&wallet.Setting{
+			Wallet: &wallet.SettingWallet{
+				URI:     server.URL,
+				Address: owner.Hex(),
+				Secret:  "0x5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515",
+			},
+			Currency: &currency.Currency{ID: "usdt", Subunits: 6, Options: options},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+
+		deadline := time.Unix(1700000000, 0)
+		permit, err := w.SignPermit(context.Background(), spender.Hex(), decimal.NewFromInt(10), deadline)
+		if !errors.Is(err, test.expected) {
+			t.Fatalf("expected %v for token version %s, got %v", test.expected, test.tokenVersion, err)
+		}
+
+		if test.expected != nil {
+			continue
+		}
+
+		if permit.Owner != owner || permit.Spender != spender || permit.Value.Int64() != 10_000_000 || permit.Nonce.Int64() != 3 || permit.Deadline.Int64() != deadline.Unix() {
+			t.Fatalf("unexpected permit %+v", permit)
+		}
+
+		word := func(b []byte) []byte { return common.LeftPadBytes(b, 32) }
+		structHash := crypto.Keccak256(
+			crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)")),
+			word(owner.Bytes()),
+			word(spender.Bytes()),
+			word(permit.Value.Bytes()),
+			word(permit.Nonce.Bytes()),
+			word(permit.Deadline.Bytes()),
+		)
+		digest := crypto.Keccak256([]byte("\x19\x01"), domainSeparator, structHash)
+
+		signature := append(append(permit.R[:], permit.S[:]...), permit.V-27)
+		publicKey, err := crypto.SigToPub(digest, signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if signer := crypto.PubkeyToAddress(*publicKey); signer != owner {
+			t.Fatalf("expected the permit to be signed by %s, got %s", owner.Hex(), signer.Hex())
+		}
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -17,54 +20,206 @@ import (
 	"github.com/zsmartex/multichain/pkg/block"
 	"github.com/zsmartex/multichain/pkg/blockchain"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/watchlist"
 )
 
-var abiDefinition = `[{"constant":true,"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_spender","type":"address"},{"name":"_value","type":"uint256"}],"name":"approve","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[],"name":"totalSupply","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_from","type":"address"},{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transferFrom","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[{"name":"_addr","type":"address"}],"name":"getNonce","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"founder","outputs":[{"name":"","type":"address"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"version","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"allocateEndBlock","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"balance","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_from","type":"address"},{"name":"_spender","type":"address"},{"name":"_value","type":"uint256"},{"name":"_v","type":"uint8"},{"name":"_r","type":"bytes32"},{"name":"_s","type":"bytes32"}],"name":"approveProxy","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_owners","type":"address[]"},{"name":"_values","type":"uint256[]"}],"name":"allocateTokens","outputs":[],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":false,"inputs":[{"name":"_to","type":"address"},{"name":"_value","type":"uint256"}],"name":"transfer","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":false,"inputs":[{"name":"_spender","type":"address"},{"name":"_value","type":"uint256"},{"name":"_extraData","type":"bytes"}],"name":"approveAndCallcode","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":false,"inputs":[{"name":"_spender","type":"address"},{"name":"_value","type":"uint256"},{"name":"_extraData","type":"bytes"}],"name":"approveAndCall","outputs":[{"name":"success","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"constant":true,"inputs":[{"name":"_owner","type":"address"},{"name":"_spender","type":"address"}],"name":"allowance","outputs":[{"name":"remaining","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":true,"inputs":[],"name":"allocateStartBlock","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},{"constant":false,"inputs":[{"name":"_from","type":"address"},{"name":"_to","type":"address"},{"name":"_value","type":"uint256"},{"name":"_feeUgt","type":"uint256"},{"name":"_v","type":"uint8"},{"name":"_r","type":"bytes32"},{"name":"_s","type":"bytes32"}],"name":"transferProxy","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},{"inputs":[],"payable":false,"stateMutability":"nonpayable","type":"constructor"},{"payable":false,"stateMutability":"nonpayable","type":"fallback"},{"anonymous":false,"inputs":[{"indexed":true,"name":"_from","type":"address"},{"indexed":true,"name":"_to","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Transfer","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"name":"_owner","type":"address"},{"indexed":true,"name":"_spender","type":"address"},{"indexed":false,"name":"_value","type":"uint256"}],"name":"Approval","type":"event"}]`
 var tokenEventIdentifier = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
 
 type Blockchain struct {
 	currency  *currency.Currency
 	contracts []*currency.Currency
 	client    *ethclient.Client
+	rpcClient *rpc.Client
 	setting   *blockchain.Setting
+	watchlist watchlist.Watchlist
+	abis      *ABIRegistry
+	scanMode  ScanMode
+	traceMode TraceMode
+
+	multicallAddress common.Address
+	blockReceipts    int32 // eth_getBlockReceipts support, see featureUnknown
+	multicall        int32 // multicall contract deployment, see featureUnknown
+
+	confirmationTag ConfirmationTag
+	pending         blockchain.PendingTracker // the transactions seen pending
+}
+
+func init() {
+	blockchain.Register("evm", NewBlockchain)
 }
 
 func NewBlockchain() blockchain.Blockchain {
 	return &Blockchain{
 		contracts: make([]*currency.Currency, 0),
+		abis:      NewABIRegistry(),
 	}
 }
 
-func (b *Blockchain) Configure(setting *blockchain.Setting) {
+// SetABIRegistry replace the registry used to call the token contracts and decode their events
+func (b *Blockchain) SetABIRegistry(abis *ABIRegistry) {
+	b.abis = abis
+}
+
+func (b *Blockchain) Configure(setting *blockchain.Setting) error {
+	if err := setting.Validate(); err != nil {
+		return fmt.Errorf("failed to configure evm blockchain: %w", err)
+	}
+
+	if err := validateURI(setting.URI); err != nil {
+		return fmt.Errorf("failed to configure evm blockchain: %w", err)
+	}
+
+	var native *currency.Currency
+	contracts := make([]*currency.Currency, 0)
+	abis := make([]*abi.ABI, len(setting.Currencies))
+	for i, c := range setting.Currencies {
+		for _, standard := range tokenStandards {
+			if address, ok := c.Options[standard.OptionKey()]; ok && address != nil {
+				if _, ok := address.(string); !ok {
+					return fmt.Errorf("failed to configure evm blockchain: %w: currency %s %s must be a string", errors.ErrInvalidConfiguration, c.ID, standard.OptionKey())
+				}
+			}
+		}
+
+		parsed, err := b.abis.parseCurrency(c)
+		if err != nil {
+			return fmt.Errorf("failed to configure evm blockchain: %w", err)
+		}
+		abis[i] = parsed
+
+		if address, _ := contractOf(c); len(address) > 0 {
+			contracts = append(contracts, c)
+		} else {
+			native = c
+		}
+	}
+
+	if native == nil {
+		return fmt.Errorf("failed to configure evm blockchain: %w: native currency is missing", errors.ErrInvalidConfiguration)
+	}
+
+	scanMode := ScanModeTransactions
+	if mode, ok := setting.Options["scan_mode"]; ok {
+		if s, ok := mode.(string); ok && (ScanMode(s) == ScanModeTransactions || ScanMode(s) == ScanModeLogs) {
+			scanMode = ScanMode(s)
+		} else {
+			return fmt.Errorf("failed to configure evm blockchain: %w: scan_mode %v is invalid", errors.ErrInvalidConfiguration, mode)
+		}
+	}
+
+	var traceMode TraceMode
+	if mode, ok := setting.Options["internal_transfers"]; ok {
+		if s, ok := mode.(string); ok && (TraceMode(s) == TraceModeCallTracer || TraceMode(s) == TraceModeTraceBlock) {
+			traceMode = TraceMode(s)
+		} else {
+			return fmt.Errorf("failed to configure evm blockchain: %w: internal_transfers %v is invalid", errors.ErrInvalidConfiguration, mode)
+		}
+	}
+
+	multicall := featureUnknown
+	multicallAddress := common.HexToAddress(DefaultMulticallAddress)
+	if address, ok := setting.Options["multicall_address"]; ok {
+		if s, ok := address.(string); ok && len(s) == 0 {
+			multicall = featureUnsupported
+		} else if ok && common.IsHexAddress(s) {
+			multicallAddress = common.HexToAddress(s)
+		} else {
+			return fmt.Errorf("failed to configure evm blockchain: %w: multicall_address %v is invalid", errors.ErrInvalidConfiguration, address)
+		}
+	}
+
+	confirmationTag := ConfirmationTagLatest
+	if tag, ok := setting.Options["confirmation_tag"]; ok {
+		if s, ok := tag.(string); ok && (ConfirmationTag(s) == ConfirmationTagLatest || ConfirmationTag(s) == ConfirmationTagSafe || ConfirmationTag(s) == ConfirmationTagFinalized) {
+			confirmationTag = ConfirmationTag(s)
+		} else {
+			return fmt.Errorf("failed to configure evm blockchain: %w: confirmation_tag %v is invalid", errors.ErrInvalidConfiguration, tag)
+		}
+	}
+
 	rpcClient, err := rpc.Dial(setting.URI)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to configure evm blockchain: %w", errors.Wrap(errors.ErrInvalidConfiguration, err))
 	}
 
-	client := ethclient.NewClient(rpcClient)
-	b.client = client
+	if b.client != nil {
+		b.client.Close()
+	}
+
+	for i, c := range setting.Currencies {
+		b.abis.setCurrency(c, abis[i])
+	}
+
+	b.client = ethclient.NewClient(rpcClient)
+	b.rpcClient = rpcClient
 	b.setting = setting
+	b.scanMode = scanMode
+	b.traceMode = traceMode
+	b.confirmationTag = confirmationTag
+	b.multicallAddress = multicallAddress
+	atomic.StoreInt32(&b.blockReceipts, featureUnknown)
+	atomic.StoreInt32(&b.multicall, multicall)
+	b.currency = native
+	b.contracts = contracts
+	b.watchlist = setting.AddressFilter()
+
+	return nil
+}
 
-	for _, c := range setting.Currencies {
-		if c.Options["erc20_contract_address"] != nil {
-			b.contracts = append(b.contracts, c)
-		} else {
-			b.currency = c
-		}
+// validateURI check that uri is a http(s) or ws(s) url of a json-rpc node
+func validateURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("%w: uri is invalid: %v", errors.ErrInvalidConfiguration, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("%w: uri %s must be a http(s) or ws(s) url", errors.ErrInvalidConfiguration, uri)
+	}
+
+	if len(u.Host) == 0 {
+		return fmt.Errorf("%w: uri %s has no host", errors.ErrInvalidConfiguration, uri)
+	}
+
+	return nil
+}
+
+func (b *Blockchain) Health(ctx context.Context) error {
+	if b.client == nil {
+		return fmt.Errorf("%w: evm blockchain is not configured", errors.ErrInvalidConfiguration)
 	}
+
+	return health(ctx, b.client)
+}
+
+func (b *Blockchain) Close() error {
+	if b.client != nil {
+		b.client.Close()
+	}
+
+	b.rpcClient = closedClient()
+	b.client = ethclient.NewClient(b.rpcClient)
+
+	return nil
 }
 
 func (b *Blockchain) GetLatestBlockNumber(ctx context.Context) (int64, error) {
 	blockNumber, err := b.client.BlockNumber(ctx)
+	if err != nil {
+		return 0, normalizeError(err, nil)
+	}
 
-	return int64(blockNumber), err
+	return int64(blockNumber), nil
 }
 
 func (b *Blockchain) GetBlockByNumber(ctx context.Context, blockNumber int64) (*block.Block, error) {
 	result, err := b.client.BlockByNumber(ctx, big.NewInt(blockNumber))
 	if err != nil {
-		return nil, err
+		return nil, normalizeError(err, errors.ErrBlockNotFound)
 	}
 
 	return b.GetBlockByHash(ctx, result.Hash().Hex())
@@ -72,12 +227,46 @@ func (b *Blockchain) GetBlockByNumber(ctx context.Context, blockNumber int64) (*
 
 func (b *Blockchain) GetBlockByHash(ctx context.Context, hash string) (*block.Block, error) {
 	result, err := b.client.BlockByHash(ctx, common.HexToHash(hash))
+	if err != nil {
+		return nil, normalizeError(err, errors.ErrBlockNotFound)
+	}
+
+	var transactions []*transaction.Transaction
+	if b.scanMode == ScanModeLogs {
+		transactions, err = b.buildBlockTransactionsFromLogs(ctx, result)
+	} else {
+		transactions, err = b.buildBlockTransactions(ctx, result)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	internalTransactions, err := b.buildInternalTransactions(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions = append(transactions, internalTransactions...)
+
+	return &block.Block{
+		Hash:             result.Hash().Hex(),
+		ParentHash:       result.ParentHash().Hex(),
+		Number:           result.Number().Int64(),
+		Timestamp:        time.Unix(int64(result.Time()), 0),
+		Size:             int64(result.Size()),
+		TransactionCount: len(result.Transactions()),
+		Transactions:     transactions,
+	}, nil
+}
+
+func (b *Blockchain) buildBlockTransactions(ctx context.Context, result *types.Block) ([]*transaction.Transaction, error) {
 	transactions := make([]*transaction.Transaction, 0)
 	for _, t := range result.Transactions() {
+		// skip the receipt lookup of transactions that cannot concern the watchlist
+		if b.watchlist != nil && !b.mayConcernWatchlist(t, result.Bloom()) {
+			continue
+		}
+
 		txs, err := b.buildTransaction(ctx, t)
 		if err != nil {
 			return nil, err
@@ -87,28 +276,73 @@ func (b *Blockchain) GetBlockByHash(ctx context.Context, hash string) (*block.Bl
 			tx.BlockNumber = result.Number().Int64()
 		}
 
-		transactions = append(transactions, txs...)
+		transactions = append(transactions, watchlist.FilterTransactions(b.watchlist, txs)...)
 	}
 
-	return &block.Block{
-		Hash:         result.Hash().Hex(),
-		Number:       result.Number().Int64(),
-		Transactions: transactions,
-	}, nil
+	return transactions, nil
 }
 
+// GetTransaction return the transactions of txHash, they have StatusPending while it is in the mempool
+// and the error is ErrTransactionDropped once a transaction seen pending is neither in the mempool nor in
+// a block, the error is ErrTransactionNotFound for a transaction which was never seen
 func (b *Blockchain) GetTransaction(ctx context.Context, txHash string) ([]*transaction.Transaction, error) {
-	result, _, err := b.client.TransactionByHash(ctx, common.HexToHash(txHash))
+	result, receipt, err := b.lookupTransaction(ctx, common.HexToHash(txHash))
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt == nil {
+		return b.buildPendingTransactions(result)
+	}
+
+	confirmations, err := b.confirmations(ctx, receipt.BlockNumber.Int64())
 	if err != nil {
 		return nil, err
 	}
 
-	ts, err := b.buildTransaction(ctx, result)
+	transactions, err := b.buildTransactionWithReceipt(result, receipt)
 	if err != nil {
 		return nil, err
 	}
 
-	return ts, nil
+	for _, t := range transactions {
+		t.Confirmations = null.Int64From(confirmations)
+	}
+
+	return transactions, nil
+}
+
+// lookupTransaction return the transaction of hash and its receipt, which is nil while it is pending
+func (b *Blockchain) lookupTransaction(ctx context.Context, hash common.Hash) (*types.Transaction, *txReceipt, error) {
+	result, isPending, err := b.client.TransactionByHash(ctx, hash)
+	if err != nil {
+		err = normalizeError(err, errors.ErrTransactionNotFound)
+		if errors.Is(err, errors.ErrTransactionNotFound) && b.pending.SeenPending(hash.Hex()) {
+			return nil, nil, errors.Wrap(errors.ErrTransactionDropped, err)
+		}
+
+		return nil, nil, err
+	}
+
+	if isPending {
+		b.pending.FirstSeen(hash.Hex())
+
+		return result, nil, nil
+	}
+
+	receipt, err := b.getReceipt(ctx, hash)
+	if err != nil {
+		// behind a load balancer the node serving the receipt may not have the block yet
+		if errors.Is(err, errors.ErrTransactionNotFound) {
+			return result, nil, nil
+		}
+
+		return nil, nil, err
+	}
+
+	b.pending.Forget(hash.Hex())
+
+	return result, receipt, nil
 }
 
 func (b *Blockchain) GetBalanceOfAddress(ctx context.Context, address string, currencyID string) (decimal.Decimal, error) {
@@ -118,6 +352,10 @@ func (b *Blockchain) GetBalanceOfAddress(ctx context.Context, address string, cu
 		}
 	}
 
+	if currencyID != b.currency.ID {
+		return decimal.Zero, fmt.Errorf("%w: %s", errors.ErrUnknownCurrency, currencyID)
+	}
+
 	blockNumber, err := b.GetLatestBlockNumber(ctx)
 	if err != nil {
 		return decimal.Zero, err
@@ -125,26 +363,26 @@ func (b *Blockchain) GetBalanceOfAddress(ctx context.Context, address string, cu
 
 	amount, err := b.client.BalanceAt(context.Background(), common.HexToAddress(address), big.NewInt(blockNumber))
 	if err != nil {
-		return decimal.Zero, err
+		return decimal.Zero, normalizeError(err, nil)
 	}
 
 	return decimal.NewFromBigInt(amount, -b.currency.Subunits), nil
 }
 
 func (b *Blockchain) getERC20Balance(ctx context.Context, address string, currency *currency.Currency) (decimal.Decimal, error) {
-	contractAddress := common.HexToAddress(currency.Options["erc20_contract_address"].(string))
-
-	blockNumber, err := b.GetLatestBlockNumber(ctx)
-	if err != nil {
-		return decimal.Zero, err
+	contract, standard := contractOf(currency)
+	if standard == TokenStandardERC1155 {
+		return decimal.Zero, fmt.Errorf("balance of erc1155 currency %s needs a token id", currency.ID)
 	}
 
-	abiJSON, err := abi.JSON(strings.NewReader(abiDefinition))
+	contractAddress := common.HexToAddress(contract)
+
+	blockNumber, err := b.GetLatestBlockNumber(ctx)
 	if err != nil {
 		return decimal.Zero, err
 	}
 
-	data, err := abiJSON.Pack("balanceOf", common.HexToAddress(address))
+	data, err := b.abis.ABI(currency).Pack("balanceOf", common.HexToAddress(address))
 	if err != nil {
 		return decimal.Zero, err
 	}
@@ -154,34 +392,41 @@ func (b *Blockchain) getERC20Balance(ctx context.Context, address string, curren
 		Data: data,
 	}, big.NewInt(blockNumber))
 	if err != nil {
-		return decimal.Zero, err
+		return decimal.Zero, normalizeError(err, nil)
 	}
 
 	return decimal.NewFromBigInt(new(big.Int).SetBytes(bytes), -currency.Subunits), nil
 }
 
 func (b *Blockchain) buildTransaction(ctx context.Context, tx *types.Transaction) ([]*transaction.Transaction, error) {
-	receipt, err := b.client.TransactionReceipt(ctx, tx.Hash())
+	receipt, err := b.getReceipt(ctx, tx.Hash())
 	if err != nil {
 		return nil, err
 	}
 
-	if len(receipt.Logs) > 0 {
+	return b.buildTransactionWithReceipt(tx, receipt)
+}
+
+func (b *Blockchain) buildTransactionWithReceipt(tx *types.Transaction, receipt *txReceipt) ([]*transaction.Transaction, error) {
+	switch {
+	case len(receipt.Logs) > 0:
 		return b.buildERC20Transactions(tx, receipt)
-	} else {
+	case tx.To() != nil && b.findContract(*tx.To()) != nil && b.transactionStatus(receipt) == transaction.StatusFailed:
+		// a failed token call has no log, it must not be read as a native transfer to the contract
+		return b.buildInvalidErc20Transaction(tx, receipt)
+	default:
 		return b.buildETHTransactions(tx, receipt)
 	}
 }
 
-func (b *Blockchain) buildETHTransactions(tx *types.Transaction, receipt *types.Receipt) ([]*transaction.Transaction, error) {
+func (b *Blockchain) buildETHTransactions(tx *types.Transaction, receipt *txReceipt) ([]*transaction.Transaction, error) {
 	msg, err := tx.AsMessage(types.LatestSignerForChainID(tx.ChainId()), tx.GasPrice())
 	if err != nil {
 		return nil, err
 	}
 
-	cost := decimal.NewFromBigInt(tx.Cost(), -b.currency.Subunits)
 	amount := decimal.NewFromBigInt(tx.Value(), -b.currency.Subunits)
-	fee := cost.Sub(amount)
+	fee := b.transactionFee(tx, receipt)
 
 	var toAddress string
 	if tx.To() == nil {
@@ -204,12 +449,8 @@ func (b *Blockchain) buildETHTransactions(tx *types.Transaction, receipt *types.
 	}, nil
 }
 
-func (b *Blockchain) buildERC20Transactions(tx *types.Transaction, receipt *types.Receipt) ([]*transaction.Transaction, error) {
-	if b.transactionStatus(receipt) == transaction.StatusFailed && len(receipt.Logs) == 0 {
-		return b.buildInvalidErc20Transaction(tx, receipt)
-	}
-
-	fee := decimal.NewFromBigInt(big.NewInt(int64(receipt.GasUsed*tx.GasFeeCap().Uint64())), -b.currency.Subunits)
+func (b *Blockchain) buildERC20Transactions(tx *types.Transaction, receipt *txReceipt) ([]*transaction.Transaction, error) {
+	fee := b.transactionFee(tx, receipt)
 
 	transactions := make([]*transaction.Transaction, 0)
 	for _, l := range receipt.Logs {
@@ -219,54 +460,45 @@ func (b *Blockchain) buildERC20Transactions(tx *types.Transaction, receipt *type
 		if len(l.Topics) == 0 {
 			continue
 		}
-		if len(l.Topics) < 3 {
-			continue
-		}
-		if l.Topics[0].Hex() != tokenEventIdentifier {
-			continue
-		}
 
-		// Contract: l.Address.Hex()
-		fromAddress := fmt.Sprintf("0x%s", l.Topics[1].Hex()[26:])
-		toAddress := fmt.Sprintf("0x%s", l.Topics[2].Hex()[26:])
-
-		for _, c := range b.contracts {
-			contractAddress := c.Options["erc20_contract_address"].(string)
-			if strings.EqualFold(contractAddress, l.Address.Hex()) {
-				i := new(big.Int)
-				i.SetString(common.Bytes2Hex(l.Data), 16)
-				amount := decimal.NewFromBigInt(i, -c.Subunits)
-
-				transactions = append(transactions, &transaction.Transaction{
-					Currency:    c.ID,
-					CurrencyFee: b.currency.ID,
-					TxHash:      null.StringFrom(tx.Hash().Hex()),
-					FromAddress: fromAddress,
-					ToAddress:   toAddress,
-					TxOut:       l.Index,
-					Fee:         decimal.NewNullDecimal(fee),
-					Amount:      amount,
-					Status:      b.transactionStatus(receipt),
-				})
-			}
+		txs, err := b.buildTokenTransactions(tx, l, decimal.NewNullDecimal(fee), b.transactionStatus(receipt))
+		if err != nil {
+			return nil, err
 		}
+
+		transactions = append(transactions, txs...)
 	}
 
 	return transactions, nil
 }
 
-func (b *Blockchain) buildInvalidErc20Transaction(tx *types.Transaction, receipt *types.Receipt) ([]*transaction.Transaction, error) {
-	fee := decimal.NewFromBigInt(big.NewInt(int64(receipt.GasUsed*tx.GasFeeCap().Uint64())), -b.currency.Subunits)
+func (b *Blockchain) buildInvalidErc20Transaction(tx *types.Transaction, receipt *txReceipt) ([]*transaction.Transaction, error) {
+	fee := b.transactionFee(tx, receipt)
+
+	var fromAddress, toAddress string
+	if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+		fromAddress = from.Hex()
+	}
+
+	if parties, ok := tokenTransferParties(tx.Data()); ok {
+		if len(parties) == 2 {
+			fromAddress = parties[0].Hex()
+		}
+
+		toAddress = parties[len(parties)-1].Hex()
+	}
 
 	transactions := make([]*transaction.Transaction, 0)
 
 	for _, c := range b.contracts {
-		if c.Options["erc20_contract_address"] == tx.To().Hex() {
+		if address, _ := contractOf(c); strings.EqualFold(address, tx.To().Hex()) {
 			transactions = append(transactions, &transaction.Transaction{
 				TxHash:      null.StringFrom(tx.Hash().Hex()),
 				BlockNumber: receipt.BlockNumber.Int64(),
 				CurrencyFee: b.currency.ID,
 				Currency:    c.ID,
+				FromAddress: fromAddress,
+				ToAddress:   toAddress,
 				Fee:         decimal.NewNullDecimal(fee),
 				Status:      b.transactionStatus(receipt),
 			})
@@ -276,7 +508,7 @@ func (b *Blockchain) buildInvalidErc20Transaction(tx *types.Transaction, receipt
 	return transactions, nil
 }
 
-func (b *Blockchain) transactionStatus(receiptTx *types.Receipt) transaction.Status {
+func (b *Blockchain) transactionStatus(receiptTx *txReceipt) transaction.Status {
 	switch receiptTx.Status {
 	case 1:
 		return transaction.StatusSucceed
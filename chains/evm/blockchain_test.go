@@ -6,12 +6,13 @@ import (
 
 	"github.com/zsmartex/multichain/pkg/blockchain"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 )
 
-func newBlockchain() blockchain.Blockchain {
+func newBlockchain(t *testing.T) blockchain.Blockchain {
 	bl := NewBlockchain()
 
-	bl.Configure(&blockchain.Setting{
+	if err := bl.Configure(&blockchain.Setting{
 		URI: "http://135.181.59.155:8575",
 		Currencies: []*currency.Currency{
 			{
@@ -26,57 +27,66 @@ func newBlockchain() blockchain.Blockchain {
 				},
 			},
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	return bl
 }
 
 func TestBlockchain_GetLatestBlockNumber(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	blockNumber, err := bl.GetLatestBlockNumber(context.Background())
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	t.Log(blockNumber)
 }
 
 func TestBlockchain_GetBlockByNumber(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	block, err := bl.GetBlockByNumber(context.Background(), 23200280)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	if len(block.Transactions) == 0 {
+		t.Fatal("expected block 23200280 to have transactions")
 	}
 
 	t.Log(block.Transactions[0])
 }
 
 func TestBlockchain_GetBlockByHash(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	block, err := bl.GetBlockByHash(context.Background(), "0xf392b3a6808bde337a299ec1e6aaacbf93de86bab8e1a4dcdc3aeaa6e742a41b")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	t.Log(block)
 }
 
 func TestBlockchain_GetTransaction(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	// EVM Transaction
 	txEvm, err := bl.GetTransaction(context.Background(), "0x8c7e11005dcab3048e0ec3bc8b13ab76d5fe3b9261d0410fe431c5e20641fe7c")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	t.Log("EVM Transaction: ", txEvm)
 
 	// ERC20 Transaction
 	txErc20, err := bl.GetTransaction(context.Background(), "0x56a12c1101e7d3916047ac969f71e121565ada81dcae3d6976e3d32bb9b4b11b")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	t.Log("ERC20 Transaction: ", txErc20)
 
@@ -84,12 +94,53 @@ func TestBlockchain_GetTransaction(t *testing.T) {
 }
 
 func TestBlockchain_GetBalanceOfAddress(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
-	balance, err := bl.GetBalanceOfAddress(context.Background(), "0xF37111De2f6AE2f64Be1e59472b5C50801540C8c", "ETH")
+	balance, err := bl.GetBalanceOfAddress(context.Background(), "0xF37111De2f6AE2f64Be1e59472b5C50801540C8c", "BSC")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	t.Log(balance)
 }
+
+func TestBlockchain_Close(t *testing.T) {
+	bl := NewBlockchain()
+	if err := bl.Configure(&blockchain.Setting{
+		URI:        "http://127.0.0.1:8545",
+		Currencies: []*currency.Currency{{ID: "eth", Subunits: 18}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bl.Health(context.Background()); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+
+	if _, err := bl.GetLatestBlockNumber(context.Background()); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+
+	if _, err := bl.GetBalanceOfAddress(context.Background(), "0xF37111De2f6AE2f64Be1e59472b5C50801540C8c", "eth"); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+}
+
+func TestValidateURI(t *testing.T) {
+	for uri, valid := range map[string]bool{
+		"http://127.0.0.1:8545":    true,
+		"https://rpc.ankr.com/eth": true,
+		"wss://127.0.0.1:8546":     true,
+		"127.0.0.1:8545":           false,
+		"ftp://127.0.0.1":          false,
+		"http://":                  false,
+	} {
+		if err := validateURI(uri); (err == nil) != valid {
+			t.Errorf("validateURI(%q) = %v", uri, err)
+		}
+	}
+}
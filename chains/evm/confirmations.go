@@ -0,0 +1,63 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// ConfirmationTag is the block confirmations are counted up to
+type ConfirmationTag string
+
+const (
+	// ConfirmationTagLatest count confirmations up to the head of the chain
+	ConfirmationTagLatest ConfirmationTag = "latest"
+	// ConfirmationTagSafe count them up to the safe block, a transaction has none until
+	// its block is safe from reorgs without an adversarial validator majority
+	ConfirmationTagSafe ConfirmationTag = "safe"
+	// ConfirmationTagFinalized count them up to the finalized block, a transaction has none until
+	// its block can't be reverted, the chain must support the tag like post merge ethereum
+	ConfirmationTagFinalized ConfirmationTag = "finalized"
+)
+
+// GetConfirmations return the number of blocks from the one of txHash to the confirmation_tag block
+func (b *Blockchain) GetConfirmations(ctx context.Context, txHash string) (int64, error) {
+	_, receipt, err := b.lookupTransaction(ctx, common.HexToHash(txHash))
+	if err != nil {
+		return 0, err
+	}
+
+	if receipt == nil {
+		return 0, nil
+	}
+
+	return b.confirmations(ctx, receipt.BlockNumber.Int64())
+}
+
+// confirmations count the blocks from blockNumber to the confirmation_tag block
+func (b *Blockchain) confirmations(ctx context.Context, blockNumber int64) (int64, error) {
+	var head *struct {
+		Number *hexutil.Big `json:"number"`
+	}
+	if err := b.rpcClient.CallContext(ctx, &head, "eth_getBlockByNumber", string(b.confirmationTag), false); err != nil {
+		return 0, normalizeError(err, nil)
+	}
+
+	if head == nil || head.Number == nil {
+		return 0, fmt.Errorf("%w: %s block", errors.ErrBlockNotFound, b.confirmationTag)
+	}
+
+	return countConfirmations(blockNumber, head.Number.ToInt().Int64()), nil
+}
+
+// countConfirmations count the blocks from blockNumber to head, blocks after head have none
+func countConfirmations(blockNumber, head int64) int64 {
+	if blockNumber > head {
+		return 0
+	}
+
+	return head - blockNumber + 1
+}
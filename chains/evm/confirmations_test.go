@@ -0,0 +1,138 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zsmartex/multichain/pkg/blockchain"
+	"github.com/zsmartex/multichain/pkg/currency"
+)
+
+// newMinedServer fake a node which mined tx in block 100, heads are the block numbers of the tags
+func newMinedServer(t *testing.T, tx *types.Transaction, heads map[string]int64) *httptest.Server {
+	blockHash := common.HexToHash("0x64")
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+
+		var result interface{}
+		switch msg.Method {
+		case "eth_getTransactionByHash":
+			data, err := tx.MarshalJSON()
+			if err != nil {
+				t.Error(err)
+			}
+
+			var fields map[string]interface{}
+			json.Unmarshal(data, &fields)
+			fields["blockHash"] = blockHash
+			fields["blockNumber"] = "0x64"
+			fields["transactionIndex"] = "0x0"
+			result = fields
+		case "eth_getTransactionReceipt":
+			result = &types.Receipt{
+				Status:            types.ReceiptStatusSuccessful,
+				CumulativeGasUsed: 21000,
+				Logs:              []*types.Log{},
+				TxHash:            tx.Hash(),
+				GasUsed:           21000,
+				BlockHash:         blockHash,
+				BlockNumber:       big.NewInt(100),
+			}
+		case "eth_getBlockByNumber":
+			var tag string
+			json.Unmarshal(msg.Params[0], &tag)
+			result = map[string]interface{}{"number": hexutil.EncodeUint64(uint64(heads[tag]))}
+		default:
+			t.Errorf("unexpected method %s", msg.Method)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+	}))
+}
+
+func TestBlockchain_GetConfirmations(t *testing.T) {
+	key, err := crypto.HexToECDSA("5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	tx, err := types.SignTx(types.NewTx(&types.LegacyTx{
+		Gas:      21000,
+		GasPrice: big.NewInt(1e9),
+		To:       &to,
+		Value:    big.NewInt(1e18),
+	}), types.LatestSignerForChainID(big.NewInt(1)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newMinedServer(t, tx, map[string]int64{"latest": 111, "safe": 99, "finalized": 100})
+	defer server.Close()
+
+	for tag, expected := range map[string]int64{
+		"":          12,
+		"latest":    12,
+		"safe":      0,
+		"finalized": 1,
+	} {
+		options := map[string]interface{}{}
+		if len(tag) > 0 {
+			options["confirmation_tag"] = tag
+		}
+
+		b := NewBlockchain().(*Blockchain)
+		if err := b.Configure(&blockchain.Setting{
+			URI:        server.URL,
+			Currencies: []*currency.Currency{{ID: "eth", Subunits: 18}},
+			Options:    options,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		confirmations, err := b.GetConfirmations(context.Background(), tx.Hash().Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if confirmations != expected {
+			t.Fatalf("expected %d confirmations up to %q, got %d", expected, tag, confirmations)
+		}
+
+		transactions, err := b.GetTransaction(context.Background(), tx.Hash().Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(transactions) != 1 || transactions[0].Confirmations.Int64 != expected {
+			t.Fatalf("expected the transaction to have %d confirmations up to %q", expected, tag)
+		}
+
+		b.Close()
+	}
+}
+
+func TestBlockchain_ConfigureConfirmationTag(t *testing.T) {
+	b := NewBlockchain()
+	err := b.Configure(&blockchain.Setting{
+		URI:        "http://127.0.0.1:8545",
+		Currencies: []*currency.Currency{{ID: "eth", Subunits: 18}},
+		Options:    map[string]interface{}{"confirmation_tag": "pending"},
+	})
+	if err == nil {
+		t.Fatal("expected an invalid confirmation_tag to be rejected")
+	}
+}
@@ -0,0 +1,99 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// nodeErrors maps messages returned by geth compatible nodes to the shared errors
+var nodeErrors = []struct {
+	message string
+	kind    error
+}{
+	{"nonce too low", errors.ErrNonceTooLow},
+	{"insufficient funds", errors.ErrInsufficientFunds},
+	{"transfer amount exceeds balance", errors.ErrInsufficientFunds},
+	{"replacement transaction underpriced", errors.ErrReplacementUnderpriced},
+	{"already known", errors.ErrAlreadyKnown},
+	{"known transaction", errors.ErrAlreadyKnown},
+}
+
+// normalizeError classify an error returned by the node,
+// notFound is used when the node reports that the requested object does not exist
+func normalizeError(err error, notFound error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, errors.ErrClosed) {
+		return err
+	}
+
+	if errors.Is(err, ethereum.NotFound) {
+		if notFound == nil {
+			return err
+		}
+
+		return errors.Wrap(notFound, err)
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.StatusCode >= http.StatusInternalServerError || httpErr.StatusCode == http.StatusTooManyRequests {
+			return errors.Wrap(errors.ErrNodeUnavailable, err)
+		}
+
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return errors.Wrap(errors.ErrNodeUnavailable, err)
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, e := range nodeErrors {
+		if strings.Contains(message, e.message) {
+			return errors.Wrap(e.kind, err)
+		}
+	}
+
+	return err
+}
+
+// health check that the node answers and is not syncing
+func health(ctx context.Context, client *ethclient.Client) error {
+	progress, err := client.SyncProgress(ctx)
+	if err != nil {
+		return normalizeError(err, nil)
+	}
+
+	if progress != nil {
+		return fmt.Errorf("%w: node is syncing (%d/%d)", errors.ErrNodeUnavailable, progress.CurrentBlock, progress.HighestBlock)
+	}
+
+	return nil
+}
+
+// closedTransport is the transport of the clients once closed, every request fails with ErrClosed
+type closedTransport struct{}
+
+func (closedTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.ErrClosed
+}
+
+// closedClient return a client whose calls fail with ErrClosed, it replaces the
+// clients on Close so the calls made afterwards don't dereference a nil client
+func closedClient() *rpc.Client {
+	client, _ := rpc.DialHTTPWithClient("http://closed", &http.Client{Transport: closedTransport{}})
+
+	return client
+}
@@ -0,0 +1,43 @@
+package evm
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+func TestNormalizeError(t *testing.T) {
+	tests := []struct {
+		err      error
+		notFound error
+		kind     error
+	}{
+		{ethereum.NotFound, errors.ErrTransactionNotFound, errors.ErrTransactionNotFound},
+		{ethereum.NotFound, errors.ErrBlockNotFound, errors.ErrBlockNotFound},
+		{errors.New("nonce too low"), nil, errors.ErrNonceTooLow},
+		{errors.New("insufficient funds for gas * price + value"), nil, errors.ErrInsufficientFunds},
+		{errors.New("replacement transaction underpriced"), nil, errors.ErrReplacementUnderpriced},
+		{errors.New("already known"), nil, errors.ErrAlreadyKnown},
+		{rpc.HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}, nil, errors.ErrNodeUnavailable},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, nil, errors.ErrNodeUnavailable},
+	}
+
+	for _, test := range tests {
+		err := normalizeError(test.err, test.notFound)
+		if !errors.Is(err, test.kind) {
+			t.Errorf("expected %v to be classified as %v, got %v", test.err, test.kind, err)
+		}
+
+		if !strings.Contains(err.Error(), test.err.Error()) {
+			t.Errorf("expected %v to keep the original error", test.err)
+		}
+	}
+
+	if err := normalizeError(errors.New("execution reverted"), nil); errors.Is(err, errors.ErrNodeUnavailable) {
+		t.Errorf("expected unknown error to stay unclassified, got %v", err)
+	}
+}
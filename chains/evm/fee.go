@@ -0,0 +1,224 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/shopspring/decimal"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+type TxType string
+
+const (
+	TxTypeAuto       TxType = "auto"
+	TxTypeLegacy     TxType = "legacy"
+	TxTypeDynamicFee TxType = "dynamic_fee"
+)
+
+// number of blocks looked at by eth_feeHistory to suggest a priority fee
+const feeHistoryBlocks = 10
+
+// defaultNativeSubunits is used when the wallet doesn't know its native currency, evm coins count in wei
+const defaultNativeSubunits = 18
+
+// gasFee is the price of a gas unit, GasPrice is used by legacy transactions
+// and GasFeeCap/GasTipCap by EIP-1559 ones
+type gasFee struct {
+	Dynamic   bool
+	GasPrice  *big.Int
+	GasFeeCap *big.Int
+	GasTipCap *big.Int
+}
+
+// Max return the highest price a gas unit can cost
+func (f *gasFee) Max() *big.Int {
+	if f.Dynamic {
+		return f.GasFeeCap
+	}
+
+	return f.GasPrice
+}
+
+// Cost return the highest fee of a transaction using gasLimit
+func (f *gasFee) Cost(gasLimit uint64) *big.Int {
+	return new(big.Int).Mul(f.Max(), new(big.Int).SetUint64(gasLimit))
+}
+
+// nativeCurrency return the currency the fees are paid in: the wallet currency unless it is a token,
+// then the native currency configured before it or the native_currency option of the token
+func (w *Wallet) nativeCurrency() *currency.Currency {
+	if contract, _ := contractOf(w.currency); len(contract) == 0 {
+		return w.currency
+	}
+
+	if w.native != nil {
+		return w.native
+	}
+
+	id, _ := w.currency.Options["native_currency"].(string)
+
+	return &currency.Currency{ID: id, Subunits: defaultNativeSubunits}
+}
+
+// setFee set the fee of tx from its cost in the base unit of the native currency
+func (w *Wallet) setFee(tx *transaction.Transaction, cost *big.Int) {
+	native := w.nativeCurrency()
+
+	tx.CurrencyFee = native.ID
+	tx.Fee = decimal.NewNullDecimal(decimal.NewFromBigInt(cost, -native.Subunits))
+}
+
+func (w *Wallet) suggestFee(ctx context.Context, options Options) (*gasFee, error) {
+	dynamic := options.TxType == TxTypeDynamicFee
+	if len(options.TxType) == 0 || options.TxType == TxTypeAuto {
+		london, err := w.londonActivated(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		dynamic = london
+	}
+
+	if !dynamic {
+		if options.GasPrice.Sign() > 0 {
+			return &gasFee{GasPrice: options.GasPrice}, nil
+		}
+
+		gasPrice, err := w.calculateGasPrice(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+
+		return &gasFee{GasPrice: gasPrice}, nil
+	}
+
+	// a fixed gas price is paid the same way as a legacy transaction would
+	if options.GasPrice.Sign() > 0 && options.MaxFeePerGas == nil && options.MaxPriorityFeePerGas == nil {
+		return &gasFee{Dynamic: true, GasFeeCap: options.GasPrice, GasTipCap: options.GasPrice}, nil
+	}
+
+	history, err := w.client.FeeHistory(ctx, feeHistoryBlocks, nil, []float64{rewardPercentile(options.GasRate)})
+	if err != nil {
+		return nil, normalizeError(err, nil)
+	}
+
+	fee := dynamicFee(history, options)
+	if fee.GasTipCap == nil {
+		tip, err := w.client.SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, normalizeError(err, nil)
+		}
+
+		fee = dynamicFee(history, Options{MaxFeePerGas: options.MaxFeePerGas, MaxPriorityFeePerGas: tip})
+	}
+
+	return fee, nil
+}
+
+// dynamicFee compute the fee caps from the fee history, the tip is the average reward paid
+// in the recent blocks and the fee cap leaves room for the base fee to double
+func dynamicFee(history *ethereum.FeeHistory, options Options) *gasFee {
+	tip := options.MaxPriorityFeePerGas
+	if tip == nil {
+		sum, count := new(big.Int), int64(0)
+		for _, rewards := range history.Reward {
+			if len(rewards) > 0 && rewards[0] != nil && rewards[0].Sign() > 0 {
+				sum.Add(sum, rewards[0])
+				count++
+			}
+		}
+
+		if count == 0 {
+			return &gasFee{Dynamic: true}
+		}
+
+		tip = sum.Div(sum, big.NewInt(count))
+	}
+
+	feeCap := options.MaxFeePerGas
+	if feeCap == nil {
+		baseFee := new(big.Int)
+		if len(history.BaseFee) > 0 {
+			// the last base fee is the one of the next block
+			baseFee = history.BaseFee[len(history.BaseFee)-1]
+		}
+
+		feeCap = new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), tip)
+	}
+
+	if feeCap.Cmp(tip) < 0 {
+		tip = feeCap
+	}
+
+	return &gasFee{Dynamic: true, GasFeeCap: feeCap, GasTipCap: tip}
+}
+
+func rewardPercentile(rate wallet.GasPriceRate) float64 {
+	switch rate {
+	case wallet.GasPriceRateFast:
+		return 90
+	default:
+		return 50
+	}
+}
+
+// londonActivated check once per configured node whether blocks carry a base fee
+func (w *Wallet) londonActivated(ctx context.Context) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.london != nil {
+		return *w.london, nil
+	}
+
+	header, err := w.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return false, normalizeError(err, nil)
+	}
+
+	london := header.BaseFee != nil
+	w.london = &london
+
+	return london, nil
+}
+
+// gasLimit return the gas_limit option or the node estimate raised by gas_multiplier,
+// a limit above max_gas_limit is capped or rejected when the estimate alone exceeds it
+func (w *Wallet) gasLimit(ctx context.Context, options Options, msg ethereum.CallMsg) (uint64, error) {
+	if options.GasLimit > 0 {
+		return options.GasLimit, nil
+	}
+
+	estimate, err := w.client.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, normalizeError(err, nil)
+	}
+
+	return applyGasLimitOptions(estimate, options)
+}
+
+func applyGasLimitOptions(estimate uint64, options Options) (uint64, error) {
+	gasLimit := estimate
+
+	// plain transfers always use exactly the intrinsic gas
+	if estimate > params.TxGas && options.GasMultiplier > 1 {
+		gasLimit = uint64(math.Ceil(float64(estimate) * options.GasMultiplier))
+	}
+
+	if options.MaxGasLimit > 0 && gasLimit > options.MaxGasLimit {
+		if estimate > options.MaxGasLimit {
+			return 0, fmt.Errorf("estimated gas %d exceeds max_gas_limit %d", estimate, options.MaxGasLimit)
+		}
+
+		gasLimit = options.MaxGasLimit
+	}
+
+	return gasLimit, nil
+}
@@ -0,0 +1,106 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+func TestDynamicFee(t *testing.T) {
+	history := &ethereum.FeeHistory{
+		Reward:  [][]*big.Int{{big.NewInt(1)}, {big.NewInt(3)}, {big.NewInt(0)}},
+		BaseFee: []*big.Int{big.NewInt(100), big.NewInt(110), big.NewInt(120), big.NewInt(130)},
+	}
+
+	fee := dynamicFee(history, Options{})
+	if fee.GasTipCap.Int64() != 2 {
+		t.Errorf("expected tip of 2, got %v", fee.GasTipCap)
+	}
+
+	if fee.GasFeeCap.Int64() != 2*130+2 {
+		t.Errorf("expected fee cap of 262, got %v", fee.GasFeeCap)
+	}
+
+	fee = dynamicFee(history, Options{MaxFeePerGas: big.NewInt(1), MaxPriorityFeePerGas: big.NewInt(5)})
+	if fee.GasFeeCap.Int64() != 1 || fee.GasTipCap.Int64() != 1 {
+		t.Errorf("expected tip to be capped by the fee cap, got %v/%v", fee.GasTipCap, fee.GasFeeCap)
+	}
+
+	if fee := dynamicFee(&ethereum.FeeHistory{Reward: [][]*big.Int{{big.NewInt(0)}}}, Options{}); fee.GasTipCap != nil {
+		t.Errorf("expected no tip without rewards, got %v", fee.GasTipCap)
+	}
+
+	if cost := (&gasFee{GasPrice: big.NewInt(10)}).Cost(21000); cost.Int64() != 210000 {
+		t.Errorf("expected legacy cost of 210000, got %v", cost)
+	}
+}
+
+func TestApplyGasLimitOptions(t *testing.T) {
+	tests := []struct {
+		estimate uint64
+		options  Options
+		want     uint64
+		err      bool
+	}{
+		{21000, Options{GasMultiplier: 1.2}, 21000, false},
+		{50000, Options{GasMultiplier: 1.2}, 60000, false},
+		{50000, Options{GasMultiplier: 1.2, MaxGasLimit: 55000}, 55000, false},
+		{60000, Options{GasMultiplier: 1.2, MaxGasLimit: 55000}, 0, true},
+		{50000, Options{}, 50000, false},
+	}
+
+	for _, test := range tests {
+		got, err := applyGasLimitOptions(test.estimate, test.options)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("estimate %d with %+v: expected %d (error %v), got %d (%v)", test.estimate, test.options, test.want, test.err, got, err)
+		}
+	}
+}
+
+func TestMergeOptions(t *testing.T) {
+	w := &Wallet{}
+
+	options := w.mergeOptions(defaultErc20Fee, map[string]interface{}{"gas_limit": 70000, "max_gas_limit": 100000})
+	if options.GasLimit != 70000 || options.MaxGasLimit != 100000 || options.GasMultiplier != 1.2 {
+		t.Fatalf("unexpected options %+v", options)
+	}
+
+	if _, ok := defaultErc20Fee["gas_limit"]; ok {
+		t.Fatal("expected default fee to be left untouched")
+	}
+}
+
+func TestWallet_SetFee(t *testing.T) {
+	usdt := &currency.Currency{ID: "usdt", Subunits: 6, Options: map[string]interface{}{
+		"erc20_contract_address": "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+	}}
+
+	w := NewWallet().(*Wallet)
+	for _, c := range []*currency.Currency{{ID: "bnb", Subunits: 18}, usdt} {
+		if err := w.Configure(&wallet.Setting{Currency: c}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx := &transaction.Transaction{}
+	w.setFee(tx, big.NewInt(21000e9))
+	if tx.CurrencyFee != "bnb" || tx.Fee.Decimal.String() != "0.000021" {
+		t.Fatalf("expected a fee of 0.000021 bnb, got %s %s", tx.Fee.Decimal, tx.CurrencyFee)
+	}
+
+	// a token wallet names its native currency with an option
+	usdt.Options["native_currency"] = "eth"
+	w = NewWallet().(*Wallet)
+	if err := w.Configure(&wallet.Setting{Currency: usdt}); err != nil {
+		t.Fatal(err)
+	}
+
+	w.setFee(tx, big.NewInt(21000e9))
+	if tx.CurrencyFee != "eth" || tx.Fee.Decimal.String() != "0.000021" {
+		t.Fatalf("expected a fee of 0.000021 eth, got %s %s", tx.Fee.Decimal, tx.CurrencyFee)
+	}
+}
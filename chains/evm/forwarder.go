@@ -0,0 +1,135 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/volatiletech/null/v9"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+// ForwarderIndexesOption is the transaction option holding the forwarder indexes a sweep flushed
+const ForwarderIndexesOption = "forwarder_indexes"
+
+// forwarderFactoryABI is the interface the forwarder factory must implement, flush deploys the
+// forwarders of salts which are not deployed yet and moves their balance of token (the zero
+// address for the native coin) to the destination the factory was deployed with
+var forwarderFactoryABI = mustParseABI(`[{"inputs":[{"name":"token","type":"address"},{"name":"salts","type":"bytes32[]"}],"name":"flush","outputs":[],"stateMutability":"nonpayable","type":"function"}]`)
+
+// forwarderOf return the factory and init code hash of the currency forwarders
+func forwarderOf(options Options) (factory common.Address, initCodeHash common.Hash, ok bool) {
+	if len(options.ForwarderFactory) == 0 {
+		return common.Address{}, common.Hash{}, false
+	}
+
+	return common.HexToAddress(options.ForwarderFactory), common.HexToHash(options.ForwarderInitCodeHash), true
+}
+
+// forwarderSalt is the CREATE2 salt of the forwarder at index
+func forwarderSalt(index uint32) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(uint64(index)))
+}
+
+// validateForwarder check the forwarder options of a currency, the factory and the init code hash go together
+func validateForwarder(c *currency.Currency) error {
+	factory, hasFactory := c.Options["forwarder_factory"]
+	initCodeHash, hasInitCodeHash := c.Options["forwarder_init_code_hash"]
+	if !hasFactory && !hasInitCodeHash {
+		return nil
+	}
+
+	if s, ok := factory.(string); !ok || !common.IsHexAddress(s) {
+		return fmt.Errorf("%w: currency %s forwarder_factory is invalid", errors.ErrInvalidConfiguration, c.ID)
+	}
+
+	if s, ok := initCodeHash.(string); !ok || len(common.FromHex(s)) != common.HashLength {
+		return fmt.Errorf("%w: currency %s forwarder_init_code_hash is invalid", errors.ErrInvalidConfiguration, c.ID)
+	}
+
+	return nil
+}
+
+// ForwarderAddress compute offline the CREATE2 address of the forwarder at index, deposits can be
+// sent to it before it is deployed, Sweep deploys and flushes it
+func (w *Wallet) ForwarderAddress(index uint32) (string, error) {
+	factory, initCodeHash, ok := forwarderOf(w.mergeOptions(nil, w.currency.Options))
+	if !ok {
+		return "", fmt.Errorf("%w: currency %s has no forwarder_factory", errors.ErrInvalidConfiguration, w.currency.ID)
+	}
+
+	salt := forwarderSalt(index)
+
+	return crypto.CreateAddress2(factory, salt, initCodeHash.Bytes()).Hex(), nil
+}
+
+// Sweep flush the balance of the wallet currency held by the forwarders at indexes to the factory
+// destination in one transaction paid by the wallet, forwarders are deployed on their first sweep
+func (w *Wallet) Sweep(ctx context.Context, indexes []uint32, opt map[string]interface{}) (*transaction.Transaction, error) {
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("sweep needs at least one forwarder")
+	}
+
+	options := w.mergeOptions(defaultErc20Fee, w.currency.Options, opt)
+
+	factory, _, ok := forwarderOf(options)
+	if !ok {
+		return nil, fmt.Errorf("%w: currency %s has no forwarder_factory", errors.ErrInvalidConfiguration, w.currency.ID)
+	}
+
+	var token common.Address
+	if contract, standard := contractOf(w.currency); isNFT(standard) {
+		return nil, fmt.Errorf("sweep of %s currency %s is not supported", standard, w.currency.ID)
+	} else if len(contract) > 0 {
+		token = common.HexToAddress(contract)
+	}
+
+	salts := make([][32]byte, len(indexes))
+	for i, index := range indexes {
+		salts[i] = [32]byte(forwarderSalt(index))
+	}
+
+	data, err := forwarderFactoryABI.Pack("flush", token, salts)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := w.suggestFee(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	fromAddress := common.HexToAddress(w.normalizeAddress(w.wallet.Address))
+	gasLimit, err := w.gasLimit(ctx, options, ethereum.CallMsg{
+		From: fromAddress,
+		To:   &factory,
+		Data: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, err := w.sendTransaction(ctx, factory, nil, data, gasLimit, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &transaction.Transaction{
+		Currency:    w.currency.ID,
+		FromAddress: w.wallet.Address,
+		ToAddress:   factory.Hex(),
+		TxHash:      null.StringFrom(signedTx.Hash().Hex()),
+		Status:      transaction.StatusPending,
+		Options: map[string]interface{}{
+			ForwarderIndexesOption: indexes,
+		},
+	}
+	w.setFee(tx, fee.Cost(gasLimit))
+
+	return tx, nil
+}
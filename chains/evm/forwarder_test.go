@@ -0,0 +1,66 @@
+package evm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+func TestWallet_ForwarderAddress(t *testing.T) {
+	// EIP-1014 examples with the init code 0x00
+	initCodeHash := crypto.Keccak256Hash([]byte{0x00}).Hex()
+
+	for factory, expected := range map[string]string{
+		"0x0000000000000000000000000000000000000000": "0x4D1A2e2bB4F88F0250f26Ffff098B0b30B26BF38",
+		"0xdeadbeef00000000000000000000000000000000": "0xB928f69Bb1D91Cd65274e3c79d8986362984fDA3",
+	} {
+		w := NewWallet().(*Wallet)
+		if err := w.Configure(&wallet.Setting{
+			Currency: &currency.Currency{
+				ID:       "usdt",
+				Subunits: 6,
+				Options: map[string]interface{}{
+					"erc20_contract_address":   "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+					"forwarder_factory":        factory,
+					"forwarder_init_code_hash": initCodeHash,
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		address, err := w.ForwarderAddress(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if address != expected {
+			t.Fatalf("expected forwarder %s of %s, got %s", expected, factory, address)
+		}
+
+		// forwarders need no gas before being swept
+		collection, err := w.PrepareDepositCollection(context.Background(), &transaction.Transaction{ToAddress: address}, nil, w.currency)
+		if !errors.Is(err, errors.ErrNotSupported) || collection != nil {
+			t.Fatalf("expected no deposit collection, got %v, %v", collection, err)
+		}
+	}
+}
+
+func TestWallet_ConfigureForwarder(t *testing.T) {
+	w := NewWallet()
+	err := w.Configure(&wallet.Setting{
+		Currency: &currency.Currency{
+			ID:       "eth",
+			Subunits: 18,
+			Options:  map[string]interface{}{"forwarder_factory": "0xdeadbeef00000000000000000000000000000000"},
+		},
+	})
+	if !errors.Is(err, errors.ErrInvalidConfiguration) {
+		t.Fatalf("expected invalid configuration without init code hash, got %v", err)
+	}
+}
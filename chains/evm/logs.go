@@ -0,0 +1,208 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/shopspring/decimal"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/watchlist"
+)
+
+type ScanMode string
+
+const (
+	// ScanModeTransactions fetch the receipt of every transaction of a block
+	ScanModeTransactions ScanMode = "transactions"
+	// ScanModeLogs fetch all the receipts of a block with eth_getBlockReceipts when the node
+	// supports it, otherwise token transfers are built from eth_getLogs and receipts are only fetched
+	// for native transfers and failed token calls of watched addresses, and the fees they paid
+	ScanModeLogs ScanMode = "logs"
+)
+
+const (
+	featureUnknown int32 = iota
+	featureSupported
+	featureUnsupported
+)
+
+// rpcMethodNotFound is the json-rpc error code of nodes which don't implement a method
+const rpcMethodNotFound = -32601
+
+func (b *Blockchain) buildBlockTransactionsFromLogs(ctx context.Context, result *types.Block) ([]*transaction.Transaction, error) {
+	receipts, err := b.getBlockReceipts(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs map[common.Hash][]*types.Log
+	if receipts == nil {
+		logs, err = b.transferLogs(ctx, result.Hash())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	transactions := make([]*transaction.Transaction, 0)
+	for _, t := range result.Transactions() {
+		if b.watchlist != nil && !b.mayConcernWatchlist(t, result.Bloom()) {
+			continue
+		}
+
+		var txs []*transaction.Transaction
+		if receipt, ok := receipts[t.Hash()]; ok {
+			txs, err = b.buildTransactionWithReceipt(t, receipt)
+		} else if txLogs, ok := logs[t.Hash()]; ok {
+			txs, err = b.buildLogTransactions(ctx, t, txLogs)
+		} else if receipts == nil && b.mayCarryDeposit(t) {
+			txs, err = b.buildTransaction(ctx, t)
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txs {
+			tx.BlockNumber = result.Number().Int64()
+		}
+
+		transactions = append(transactions, watchlist.FilterTransactions(b.watchlist, txs)...)
+	}
+
+	return transactions, nil
+}
+
+// mayCarryDeposit tell whether a transaction without transfer logs needs its receipt when the
+// block receipts are not available: native transfers and failed calls to the token contracts,
+// with a watchlist only those sent from or to a watched address
+func (b *Blockchain) mayCarryDeposit(tx *types.Transaction) bool {
+	var contract *currency.Currency
+	if tx.To() != nil {
+		contract = b.findContract(*tx.To())
+	}
+
+	if tx.Value().Sign() <= 0 && contract == nil {
+		return false
+	}
+
+	if b.watchlist == nil {
+		return true
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil || b.watchlist.Contains(from.Hex()) || (tx.To() != nil && b.watchlist.Contains(tx.To().Hex())) {
+		return true
+	}
+
+	if contract != nil {
+		parties, _ := tokenTransferParties(tx.Data())
+		for _, party := range parties {
+			if b.watchlist.Contains(party.Hex()) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// buildLogTransactions build the token transfers of tx from its logs returned by eth_getLogs, a transaction
+// which emitted logs succeeded so the receipt is only fetched for the fee when a watched address paid it
+func (b *Blockchain) buildLogTransactions(ctx context.Context, tx *types.Transaction, logs []*types.Log) ([]*transaction.Transaction, error) {
+	if b.watchlist != nil {
+		if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err != nil || b.watchlist.Contains(from.Hex()) {
+			return b.buildTransaction(ctx, tx)
+		}
+	}
+
+	transactions := make([]*transaction.Transaction, 0)
+	for _, l := range logs {
+		txs, err := b.buildTokenTransactions(tx, l, decimal.NullDecimal{}, transaction.StatusSucceed)
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, txs...)
+	}
+
+	return transactions, nil
+}
+
+// transferLogs return the transfer events of the configured contracts emitted in the block by transaction hash
+func (b *Blockchain) transferLogs(ctx context.Context, blockHash common.Hash) (map[common.Hash][]*types.Log, error) {
+	logged := make(map[common.Hash][]*types.Log)
+	if len(b.contracts) == 0 {
+		return logged, nil
+	}
+
+	addresses := make([]common.Address, 0, len(b.contracts))
+	for _, c := range b.contracts {
+		address, _ := contractOf(c)
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+
+	logs, err := b.client.FilterLogs(ctx, ethereum.FilterQuery{
+		BlockHash: &blockHash,
+		Addresses: addresses,
+		Topics:    [][]common.Hash{{common.HexToHash(tokenEventIdentifier), transferSingleEventIdentifier, transferBatchEventIdentifier}},
+	})
+	if err != nil {
+		return nil, normalizeError(err, errors.ErrBlockNotFound)
+	}
+
+	for i := range logs {
+		if logs[i].Removed {
+			continue
+		}
+
+		logged[logs[i].TxHash] = append(logged[logs[i].TxHash], &logs[i])
+	}
+
+	return logged, nil
+}
+
+// getBlockReceipts return the receipts of the block by transaction hash or nil when the node
+// doesn't support eth_getBlockReceipts, a node is only marked unsupported when it doesn't know the method
+func (b *Blockchain) getBlockReceipts(ctx context.Context, result *types.Block) (map[common.Hash]*txReceipt, error) {
+	if atomic.LoadInt32(&b.blockReceipts) == featureUnsupported {
+		return nil, nil
+	}
+
+	var receipts []*txReceipt
+	if err := b.rpcClient.CallContext(ctx, &receipts, "eth_getBlockReceipts", result.Hash().Hex()); err != nil {
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == rpcMethodNotFound {
+			atomic.StoreInt32(&b.blockReceipts, featureUnsupported)
+
+			return nil, nil
+		}
+
+		return nil, normalizeError(err, errors.ErrBlockNotFound)
+	}
+
+	// pruned or lagging nodes may answer with a partial list
+	if len(receipts) != len(result.Transactions()) {
+		return nil, nil
+	}
+
+	atomic.StoreInt32(&b.blockReceipts, featureSupported)
+
+	byHash := make(map[common.Hash]*txReceipt, len(receipts))
+	for _, receipt := range receipts {
+		if receipt.BlockNumber == nil {
+			receipt.BlockNumber = new(big.Int).Set(result.Number())
+		}
+
+		byHash[receipt.TxHash] = receipt
+	}
+
+	return byHash, nil
+}
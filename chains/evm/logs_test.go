@@ -0,0 +1,241 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/zsmartex/multichain/pkg/blockchain"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/watchlist"
+)
+
+func TestGetBlockReceipts_Unsupported(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method eth_getBlockReceipts does not exist/is not available"}}`))
+	}))
+	defer server.Close()
+
+	rpcClient, err := rpc.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rpcClient.Close()
+
+	b := &Blockchain{rpcClient: rpcClient}
+	block := types.NewBlockWithHeader(&types.Header{})
+
+	for i := 0; i < 2; i++ {
+		receipts, err := b.getBlockReceipts(context.Background(), block)
+		if err != nil || receipts != nil {
+			t.Fatalf("expected no receipts and no error, got %v, %v", receipts, err)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected support to be checked once, got %d calls", calls)
+	}
+}
+
+func TestConfigure_ScanMode(t *testing.T) {
+	setting := &blockchain.Setting{
+		URI:        "http://127.0.0.1:8545",
+		Currencies: []*currency.Currency{{ID: "eth", Subunits: 18}},
+		Options:    map[string]interface{}{"scan_mode": "blocks"},
+	}
+
+	b := NewBlockchain()
+	if err := b.Configure(setting); !errors.Is(err, errors.ErrInvalidConfiguration) {
+		t.Fatalf("expected invalid configuration, got %v", err)
+	}
+
+	setting.Options["scan_mode"] = string(ScanModeLogs)
+	if err := b.Configure(setting); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if mode := b.(*Blockchain).scanMode; mode != ScanModeLogs {
+		t.Fatalf("expected logs scan mode, got %s", mode)
+	}
+}
+
+func TestGetBlockReceipts_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}`))
+	}))
+	defer server.Close()
+
+	rpcClient, err := rpc.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rpcClient.Close()
+
+	b := &Blockchain{rpcClient: rpcClient}
+	if _, err := b.getBlockReceipts(context.Background(), types.NewBlockWithHeader(&types.Header{})); err == nil {
+		t.Fatal("expected the node error to be returned")
+	}
+
+	if b.blockReceipts != featureUnknown {
+		t.Fatal("expected a node error not to mark eth_getBlockReceipts unsupported")
+	}
+}
+
+func TestBuildBlockTransactionsFromLogs(t *testing.T) {
+	key, err := crypto.HexToECDSA("5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	deposit := common.HexToAddress("0xf37111de2f6ae2f64be1e59472b5c50801540c8c")
+	usdt := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+
+	data, err := erc20ABI.Pack("transfer", deposit, big.NewInt(5e6))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Gas:       60000,
+		GasFeeCap: big.NewInt(1e9),
+		GasTipCap: big.NewInt(1e9),
+		To:        &usdt,
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100)}).WithBody([]*types.Transaction{tx}, nil)
+
+	receipts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch msg.Method {
+		case "eth_getBlockReceipts":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method eth_getBlockReceipts does not exist/is not available"}}`))
+			return
+		case "eth_getLogs":
+			msg.Result = []*types.Log{{
+				Address:     usdt,
+				Topics:      []common.Hash{common.HexToHash(tokenEventIdentifier), common.BytesToHash(sender.Bytes()), common.BytesToHash(deposit.Bytes())},
+				Data:        common.LeftPadBytes(big.NewInt(5e6).Bytes(), 32),
+				BlockNumber: 100,
+				TxHash:      tx.Hash(),
+				BlockHash:   result.Hash(),
+				Index:       3,
+			}}
+		case "eth_getTransactionReceipt":
+			receipts++
+		default:
+			t.Errorf("unexpected method %s", msg.Method)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": msg.Result})
+	}))
+	defer server.Close()
+
+	b := newPendingBlockchain(t, server.URL)
+	defer b.Close()
+	b.watchlist = watchlist.NewSet(deposit.Hex())
+
+	transactions, err := b.buildBlockTransactionsFromLogs(context.Background(), result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receipts != 0 {
+		t.Fatalf("expected a deposit to be built without its receipt, got %d receipt calls", receipts)
+	}
+
+	if len(transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(transactions))
+	}
+
+	trans := transactions[0]
+	if trans.Currency != "usdt" || trans.Amount.String() != "5" || trans.ToAddress != deposit.Hex() || trans.TxOut != 3 || trans.Status != transaction.StatusSucceed {
+		t.Fatalf("unexpected transaction %+v", trans)
+	}
+
+	// the fee of a withdrawal from a watched address comes from the receipt
+	b.watchlist = watchlist.NewSet(sender.Hex())
+	if _, err := b.buildBlockTransactionsFromLogs(context.Background(), result); !errors.Is(err, errors.ErrTransactionNotFound) || receipts != 1 {
+		t.Fatalf("expected the receipt of a watched sender to be fetched, got %d calls and %v", receipts, err)
+	}
+}
+
+func TestMayCarryDeposit(t *testing.T) {
+	key, err := crypto.HexToECDSA("5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	deposit := common.HexToAddress("0xf37111de2f6ae2f64be1e59472b5c50801540c8c")
+	router := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	usdt := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+
+	transfer, err := erc20ABI.Pack("transfer", deposit, big.NewInt(5e6))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newPendingBlockchain(t, "http://127.0.0.1:8545")
+	defer b.Close()
+
+	for name, test := range map[string]struct {
+		to        common.Address
+		value     *big.Int
+		data      []byte
+		watchlist watchlist.Watchlist
+		expected  bool
+	}{
+		"native without watchlist":     {to: router, value: big.NewInt(1), expected: true},
+		"call without watchlist":       {to: router, value: big.NewInt(0), data: []byte{1}, expected: false},
+		"native to watched":            {to: deposit, value: big.NewInt(1), watchlist: watchlist.NewSet(deposit.Hex()), expected: true},
+		"native from watched":          {to: router, value: big.NewInt(1), watchlist: watchlist.NewSet(sender.Hex()), expected: true},
+		"native of others":             {to: router, value: big.NewInt(1), data: []byte{1}, watchlist: watchlist.NewSet(deposit.Hex()), expected: false},
+		"token transfer to watched":    {to: usdt, value: big.NewInt(0), data: transfer, watchlist: watchlist.NewSet(deposit.Hex()), expected: true},
+		"token transfer of others":     {to: usdt, value: big.NewInt(0), data: transfer, watchlist: watchlist.NewSet(router.Hex()), expected: false},
+		"token call without watchlist": {to: usdt, value: big.NewInt(0), data: transfer, expected: true},
+	} {
+		tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			Gas:       60000,
+			GasFeeCap: big.NewInt(1e9),
+			GasTipCap: big.NewInt(1e9),
+			To:        &test.to,
+			Value:     test.value,
+			Data:      test.data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b.watchlist = test.watchlist
+		if carry := b.mayCarryDeposit(tx); carry != test.expected {
+			t.Errorf("%s: expected mayCarryDeposit to be %t", name, test.expected)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// SignMessage sign message with the wallet key as personal_sign does (EIP-191)
+func (w *Wallet) SignMessage(_ context.Context, message []byte) (string, error) {
+	return w.signHash(accounts.TextHash(message))
+}
+
+// VerifyMessage check a personal_sign signature (EIP-191) of message by address
+func (w *Wallet) VerifyMessage(_ context.Context, address string, message []byte, signature string) (bool, error) {
+	return verifyHash(address, accounts.TextHash(message), signature)
+}
+
+// SignTypedData sign EIP-712 typed data with the wallet key as eth_signTypedData_v4 does
+func (w *Wallet) SignTypedData(_ context.Context, typedData apitypes.TypedData) (string, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return "", err
+	}
+
+	return w.signHash(hash)
+}
+
+// VerifyTypedData check an EIP-712 signature of typed data by address
+func (w *Wallet) VerifyTypedData(_ context.Context, address string, typedData apitypes.TypedData, signature string) (bool, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return false, err
+	}
+
+	return verifyHash(address, hash, signature)
+}
+
+func (w *Wallet) signHash(hash []byte) (string, error) {
+	privateKey, err := w.privateKey(w.wallet.Secret)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := crypto.Sign(hash, privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	// wallets use 27 and 28 as recovery id
+	signature[crypto.RecoveryIDOffset] += 27
+
+	return hexutil.Encode(signature), nil
+}
+
+func verifyHash(address string, hash []byte, signature string) (bool, error) {
+	if !common.IsHexAddress(address) {
+		return false, fmt.Errorf("address %s is invalid", address)
+	}
+
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return false, fmt.Errorf("signature %s is invalid", signature)
+	}
+
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return false, nil
+	}
+
+	return crypto.PubkeyToAddress(*publicKey) == common.HexToAddress(address), nil
+}
@@ -0,0 +1,88 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+func newMessageWallet(t *testing.T) *Wallet {
+	w := NewWallet().(*Wallet)
+	if err := w.Configure(&wallet.Setting{
+		Wallet: &wallet.SettingWallet{
+			URI:     "http://127.0.0.1:8545",
+			Address: "0xD2E03d98cd8af2D84522Cf11D471AaA4ca60D8CA",
+			Secret:  "0x5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func TestWallet_SignMessage(t *testing.T) {
+	w := newMessageWallet(t)
+	defer w.Close()
+
+	ctx := context.Background()
+	message := []byte("proof of reserves 2024-01-01")
+
+	signature, err := w.SignMessage(ctx, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for address, expected := range map[string]bool{
+		"0xD2E03d98cd8af2D84522Cf11D471AaA4ca60D8CA": true,
+		"0x4444444444444444444444444444444444444444": false,
+	} {
+		ok, err := w.VerifyMessage(ctx, address, message, signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok != expected {
+			t.Fatalf("expected verification of %s to be %v", address, expected)
+		}
+	}
+
+	if ok, _ := w.VerifyMessage(ctx, w.wallet.Address, []byte("tampered"), signature); ok {
+		t.Fatal("expected a tampered message to be rejected")
+	}
+
+	if _, err := w.VerifyMessage(ctx, w.wallet.Address, message, "0x01"); err == nil {
+		t.Fatal("expected a malformed signature to be an error")
+	}
+}
+
+func TestWallet_SignTypedData(t *testing.T) {
+	w := newMessageWallet(t)
+	defer w.Close()
+
+	ctx := context.Background()
+	typedData := permitTypedData("Tether USD", "1", big.NewInt(1), common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7"), &Permit{
+		Owner:    common.HexToAddress(w.wallet.Address),
+		Spender:  common.HexToAddress("0x4444444444444444444444444444444444444444"),
+		Value:    big.NewInt(1),
+		Nonce:    big.NewInt(0),
+		Deadline: big.NewInt(1700000000),
+	})
+
+	signature, err := w.SignTypedData(ctx, typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := w.VerifyTypedData(ctx, w.wallet.Address, typedData, signature); err != nil || !ok {
+		t.Fatalf("expected the typed data signature to verify, got %v, %v", ok, err)
+	}
+
+	// a personal_sign signature of the same bytes must not pass for typed data
+	if ok, _ := w.VerifyMessage(ctx, w.wallet.Address, []byte("Tether USD"), signature); ok {
+		t.Fatal("expected typed data and personal messages to be hashed differently")
+	}
+}
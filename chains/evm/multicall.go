@@ -0,0 +1,321 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/shopspring/decimal"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// DefaultMulticallAddress is the address Multicall3 is deployed at on most evm chains,
+// the multicall_address option overrides it and an empty value disables it
+const DefaultMulticallAddress = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+const (
+	multicallBatchSize = 500 // calls aggregated in a single eth_call
+	rpcBatchSize       = 20  // requests sent in a single json-rpc batch
+)
+
+var balanceOfMethodID = common.FromHex("0x70a08231")
+
+var multicallABI = mustParseABI(`[{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"},{"inputs":[{"name":"addr","type":"address"}],"name":"getEthBalance","outputs":[{"name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"}]`)
+
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// balanceCall is the balance of one address in one currency
+type balanceCall struct {
+	address  common.Address
+	currency *currency.Currency
+	contract *common.Address // nil for the native currency
+}
+
+func (c *balanceCall) callData() []byte {
+	return append(common.CopyBytes(balanceOfMethodID), common.LeftPadBytes(c.address.Bytes(), 32)...)
+}
+
+type callArgs struct {
+	To   common.Address `json:"to"`
+	Data hexutil.Bytes  `json:"data"`
+}
+
+// GetBalances return the balances of every address in every currency, keyed by address then currency id.
+// The balances are read at the same block through Multicall3 when the chain has it,
+// otherwise through batches of eth_getBalance and eth_call requests
+func (b *Blockchain) GetBalances(ctx context.Context, addresses []string, currencyIDs []string) (map[string]map[string]decimal.Decimal, error) {
+	currencies := make([]*currency.Currency, 0, len(currencyIDs))
+	for _, id := range currencyIDs {
+		c, err := b.findCurrency(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, standard := contractOf(c); standard == TokenStandardERC1155 {
+			return nil, fmt.Errorf("balance of erc1155 currency %s needs a token id", c.ID)
+		}
+
+		currencies = append(currencies, c)
+	}
+
+	calls := make([]*balanceCall, 0, len(addresses)*len(currencies))
+	for _, address := range addresses {
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("address %s is invalid", address)
+		}
+
+		for _, c := range currencies {
+			call := &balanceCall{address: common.HexToAddress(address), currency: c}
+			if contract, _ := contractOf(c); len(contract) > 0 {
+				contractAddress := common.HexToAddress(contract)
+				call.contract = &contractAddress
+			}
+
+			calls = append(calls, call)
+		}
+	}
+
+	balances := make(map[string]map[string]decimal.Decimal, len(addresses))
+	for _, address := range addresses {
+		balances[address] = make(map[string]decimal.Decimal, len(currencies))
+	}
+
+	if len(calls) == 0 {
+		return balances, nil
+	}
+
+	blockNumber, err := b.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	block := hexutil.EncodeBig(big.NewInt(blockNumber))
+
+	multicall, err := b.multicallAvailable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var amounts []*big.Int
+	if multicall {
+		amounts, err = b.multicallBalances(ctx, calls, block)
+	} else {
+		amounts, err = b.batchBalances(ctx, calls, block)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, call := range calls {
+		address := addresses[i/len(currencies)]
+		balances[address][call.currency.ID] = decimal.NewFromBigInt(amounts[i], -call.currency.Subunits)
+	}
+
+	return balances, nil
+}
+
+func (b *Blockchain) findCurrency(id string) (*currency.Currency, error) {
+	if b.currency.ID == id {
+		return b.currency, nil
+	}
+
+	for _, c := range b.contracts {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", errors.ErrUnknownCurrency, id)
+}
+
+// multicallAvailable check once that the multicall contract is deployed on the chain
+func (b *Blockchain) multicallAvailable(ctx context.Context) (bool, error) {
+	switch atomic.LoadInt32(&b.multicall) {
+	case featureSupported:
+		return true, nil
+	case featureUnsupported:
+		return false, nil
+	}
+
+	code, err := b.client.CodeAt(ctx, b.multicallAddress, nil)
+	if err != nil {
+		return false, normalizeError(err, nil)
+	}
+
+	if len(code) == 0 {
+		atomic.StoreInt32(&b.multicall, featureUnsupported)
+		return false, nil
+	}
+
+	atomic.StoreInt32(&b.multicall, featureSupported)
+
+	return true, nil
+}
+
+func (b *Blockchain) multicallBalances(ctx context.Context, calls []*balanceCall, block string) ([]*big.Int, error) {
+	elems := make([]rpc.BatchElem, 0, len(calls)/multicallBatchSize+1)
+	for start := 0; start < len(calls); start += multicallBatchSize {
+		end := start + multicallBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+
+		aggregated := make([]multicall3Call, 0, end-start)
+		for _, call := range calls[start:end] {
+			if call.contract == nil {
+				data, err := multicallABI.Pack("getEthBalance", call.address)
+				if err != nil {
+					return nil, err
+				}
+
+				aggregated = append(aggregated, multicall3Call{Target: b.multicallAddress, AllowFailure: true, CallData: data})
+			} else {
+				aggregated = append(aggregated, multicall3Call{Target: *call.contract, AllowFailure: true, CallData: call.callData()})
+			}
+		}
+
+		data, err := multicallABI.Pack("aggregate3", aggregated)
+		if err != nil {
+			return nil, err
+		}
+
+		elems = append(elems, rpc.BatchElem{
+			Method: "eth_call",
+			Args:   []interface{}{callArgs{To: b.multicallAddress, Data: data}, block},
+			Result: new(hexutil.Bytes),
+		})
+	}
+
+	if err := b.batchCall(ctx, elems); err != nil {
+		return nil, err
+	}
+
+	amounts := make([]*big.Int, 0, len(calls))
+	for _, elem := range elems {
+		results, err := decodeAggregate3(*elem.Result.(*hexutil.Bytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode multicall result: %v", err)
+		}
+
+		expected := len(calls) - len(amounts)
+		if expected > multicallBatchSize {
+			expected = multicallBatchSize
+		}
+
+		if len(results) != expected {
+			return nil, fmt.Errorf("multicall returned %d balances for %d calls", len(results), expected)
+		}
+
+		for _, result := range results {
+			call := calls[len(amounts)]
+			if !result.Success {
+				return nil, fmt.Errorf("balance of %s in %s reverted", call.address.Hex(), call.currency.ID)
+			}
+
+			amount, err := decodeBalance(result.ReturnData)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode balance of %s in %s: %v", call.address.Hex(), call.currency.ID, err)
+			}
+
+			amounts = append(amounts, amount)
+		}
+	}
+
+	return amounts, nil
+}
+
+func (b *Blockchain) batchBalances(ctx context.Context, calls []*balanceCall, block string) ([]*big.Int, error) {
+	elems := make([]rpc.BatchElem, len(calls))
+	for i, call := range calls {
+		if call.contract == nil {
+			elems[i] = rpc.BatchElem{
+				Method: "eth_getBalance",
+				Args:   []interface{}{call.address, block},
+				Result: new(hexutil.Big),
+			}
+		} else {
+			elems[i] = rpc.BatchElem{
+				Method: "eth_call",
+				Args:   []interface{}{callArgs{To: *call.contract, Data: call.callData()}, block},
+				Result: new(hexutil.Bytes),
+			}
+		}
+	}
+
+	if err := b.batchCall(ctx, elems); err != nil {
+		return nil, err
+	}
+
+	amounts := make([]*big.Int, len(calls))
+	for i, elem := range elems {
+		switch result := elem.Result.(type) {
+		case *hexutil.Big:
+			amounts[i] = result.ToInt()
+		case *hexutil.Bytes:
+			amount, err := decodeBalance(*result)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode balance of %s in %s: %v", calls[i].address.Hex(), calls[i].currency.ID, err)
+			}
+
+			amounts[i] = amount
+		}
+	}
+
+	return amounts, nil
+}
+
+// batchCall send the requests in json-rpc batches of rpcBatchSize
+func (b *Blockchain) batchCall(ctx context.Context, elems []rpc.BatchElem) error {
+	for start := 0; start < len(elems); start += rpcBatchSize {
+		end := start + rpcBatchSize
+		if end > len(elems) {
+			end = len(elems)
+		}
+
+		if err := b.rpcClient.BatchCallContext(ctx, elems[start:end]); err != nil {
+			return normalizeError(err, nil)
+		}
+
+		for _, elem := range elems[start:end] {
+			if elem.Error != nil {
+				return normalizeError(elem.Error, nil)
+			}
+		}
+	}
+
+	return nil
+}
+
+func decodeAggregate3(data []byte) ([]multicall3Result, error) {
+	values, err := multicallABI.Unpack("aggregate3", data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := *abi.ConvertType(values[0], new([]multicall3Result)).(*[]multicall3Result)
+
+	return results, nil
+}
+
+func decodeBalance(data []byte) (*big.Int, error) {
+	if len(data) != 32 {
+		return nil, fmt.Errorf("unexpected result length %d", len(data))
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,196 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zsmartex/multichain/pkg/blockchain"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+var (
+	testNativeBalance = big.NewInt(1e18)
+	testTokenBalance  = big.NewInt(5e6)
+)
+
+type rpcMessage struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result interface{}       `json:"result,omitempty"`
+}
+
+// newBalanceServer fake a node answering balances, with multicall3 deployed or not
+func newBalanceServer(t *testing.T, deployed bool) (*httptest.Server, *int) {
+	batches := 0
+
+	answer := func(msg *rpcMessage) {
+		switch msg.Method {
+		case "eth_blockNumber":
+			msg.Result = "0x10"
+		case "eth_getCode":
+			if deployed {
+				msg.Result = "0x6080"
+			} else {
+				msg.Result = "0x"
+			}
+		case "eth_getBalance":
+			msg.Result = (*hexutil.Big)(testNativeBalance)
+		case "eth_call":
+			var args callArgs
+			if err := json.Unmarshal(msg.Params[0], &args); err != nil {
+				t.Error(err)
+			}
+
+			if args.To != common.HexToAddress(DefaultMulticallAddress) {
+				msg.Result = hexutil.Bytes(common.LeftPadBytes(testTokenBalance.Bytes(), 32))
+				return
+			}
+
+			method := multicallABI.Methods["aggregate3"]
+			values, err := method.Inputs.Unpack(args.Data[4:])
+			if err != nil {
+				t.Error(err)
+			}
+
+			calls := *abi.ConvertType(values[0], new([]multicall3Call)).(*[]multicall3Call)
+			results := make([]multicall3Result, len(calls))
+			for i, call := range calls {
+				balance := testTokenBalance
+				if call.Target == common.HexToAddress(DefaultMulticallAddress) {
+					balance = testNativeBalance
+				}
+
+				results[i] = multicall3Result{Success: true, ReturnData: common.LeftPadBytes(balance.Bytes(), 32)}
+			}
+
+			data, err := method.Outputs.Pack(results)
+			if err != nil {
+				t.Error(err)
+			}
+
+			msg.Result = hexutil.Bytes(data)
+		default:
+			t.Errorf("unexpected method %s", msg.Method)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if body[0] != '[' {
+			msg := new(rpcMessage)
+			json.Unmarshal(body, msg)
+			answer(msg)
+			json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": msg.Result})
+			return
+		}
+
+		batches++
+
+		var msgs []*rpcMessage
+		json.Unmarshal(body, &msgs)
+
+		responses := make([]map[string]interface{}, 0, len(msgs))
+		for _, msg := range msgs {
+			answer(msg)
+			responses = append(responses, map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": msg.Result})
+		}
+
+		json.NewEncoder(w).Encode(responses)
+	}))
+
+	return server, &batches
+}
+
+func TestGetBalances(t *testing.T) {
+	addresses := make([]string, 0, 30)
+	for i := 0; i < 30; i++ {
+		addresses = append(addresses, common.BigToAddress(big.NewInt(int64(i+1))).Hex())
+	}
+
+	for name, test := range map[string]struct {
+		deployed bool
+		options  map[string]interface{}
+		batches  int
+	}{
+		"multicall": {deployed: true, batches: 1},
+		"fallback":  {deployed: false, batches: 3},
+		"disabled":  {deployed: true, options: map[string]interface{}{"multicall_address": ""}, batches: 3},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, batches := newBalanceServer(t, test.deployed)
+			defer server.Close()
+
+			b := NewBlockchain().(*Blockchain)
+			err := b.Configure(&blockchain.Setting{
+				URI: server.URL,
+				Currencies: []*currency.Currency{
+					{ID: "eth", Subunits: 18},
+					{ID: "usdt", Subunits: 6, Options: map[string]interface{}{"erc20_contract_address": "0xdAC17F958D2ee523a2206206994597C13D831ec7"}},
+				},
+				Options: test.options,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+
+			balances, err := b.GetBalances(context.Background(), addresses, []string{"eth", "usdt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(balances) != len(addresses) {
+				t.Fatalf("expected %d addresses, got %d", len(addresses), len(balances))
+			}
+
+			for _, address := range addresses {
+				if balance := balances[address]["eth"]; balance.String() != "1" {
+					t.Fatalf("unexpected eth balance of %s: %s", address, balance)
+				}
+
+				if balance := balances[address]["usdt"]; balance.String() != "5" {
+					t.Fatalf("unexpected usdt balance of %s: %s", address, balance)
+				}
+			}
+
+			if *batches != test.batches {
+				t.Fatalf("expected %d batches, got %d", test.batches, *batches)
+			}
+		})
+	}
+}
+
+func TestGetBalances_UnknownCurrency(t *testing.T) {
+	b := &Blockchain{currency: &currency.Currency{ID: "eth", Subunits: 18}}
+
+	if _, err := b.GetBalances(context.Background(), nil, []string{"btc"}); !errors.Is(err, errors.ErrUnknownCurrency) {
+		t.Fatalf("expected unknown currency, got %v", err)
+	}
+}
+
+func TestConfigure_MulticallAddress(t *testing.T) {
+	b := NewBlockchain()
+	err := b.Configure(&blockchain.Setting{
+		URI:        "http://127.0.0.1:8545",
+		Currencies: []*currency.Currency{{ID: "eth", Subunits: 18}},
+		Options:    map[string]interface{}{"multicall_address": "multicall"},
+	})
+	if !errors.Is(err, errors.ErrInvalidConfiguration) {
+		t.Fatalf("expected invalid configuration, got %v", err)
+	}
+}
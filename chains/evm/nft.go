@@ -0,0 +1,257 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/volatiletech/null/v9"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+type TokenStandard string
+
+const (
+	TokenStandardERC20   TokenStandard = "erc20"
+	TokenStandardERC721  TokenStandard = "erc721"
+	TokenStandardERC1155 TokenStandard = "erc1155"
+)
+
+var tokenStandards = []TokenStandard{TokenStandardERC20, TokenStandardERC721, TokenStandardERC1155}
+
+// OptionKey is the currency option holding the contract address of a token of this standard
+func (s TokenStandard) OptionKey() string {
+	return string(s) + "_contract_address"
+}
+
+// TokenIDOption is the transaction option holding the token id of NFT transfers,
+// NFT transactions are unique by TxHash, TxOut and token id since a batch transfer is a single log
+const TokenIDOption = "token_id"
+
+var (
+	transferSingleEventIdentifier = common.HexToHash("0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62")
+	transferBatchEventIdentifier  = common.HexToHash("0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb")
+
+	erc721SafeTransferFromMethodID  = common.FromHex("0x42842e0e")
+	erc1155SafeTransferFromMethodID = common.FromHex("0xf242432a")
+)
+
+// contractOf return the contract address and token standard of a currency, both are empty for the native currency
+func contractOf(c *currency.Currency) (string, TokenStandard) {
+	for _, standard := range tokenStandards {
+		if address, ok := c.Options[standard.OptionKey()].(string); ok {
+			return address, standard
+		}
+	}
+
+	return "", ""
+}
+
+type tokenTransfer struct {
+	From    common.Address
+	To      common.Address
+	TokenID *big.Int // nil for erc20
+	Value   *big.Int
+}
+
+// decodeTokenTransfers decode the transfers of a log emitted by a contract of the standard
+func decodeTokenTransfers(standard TokenStandard, l *types.Log) ([]*tokenTransfer, error) {
+	switch {
+	case standard == TokenStandardERC20 && len(l.Topics) == 3 && l.Topics[0] == common.HexToHash(tokenEventIdentifier):
+		return []*tokenTransfer{{
+			From:  common.BytesToAddress(l.Topics[1].Bytes()),
+			To:    common.BytesToAddress(l.Topics[2].Bytes()),
+			Value: new(big.Int).SetBytes(l.Data),
+		}}, nil
+	case standard == TokenStandardERC721 && len(l.Topics) == 4 && l.Topics[0] == common.HexToHash(tokenEventIdentifier):
+		return []*tokenTransfer{{
+			From:    common.BytesToAddress(l.Topics[1].Bytes()),
+			To:      common.BytesToAddress(l.Topics[2].Bytes()),
+			TokenID: l.Topics[3].Big(),
+			Value:   big.NewInt(1),
+		}}, nil
+	case standard == TokenStandardERC1155 && len(l.Topics) == 4 && l.Topics[0] == transferSingleEventIdentifier:
+		if len(l.Data) != 64 {
+			return nil, fmt.Errorf("invalid TransferSingle data length %d", len(l.Data))
+		}
+
+		return []*tokenTransfer{{
+			From:    common.BytesToAddress(l.Topics[2].Bytes()),
+			To:      common.BytesToAddress(l.Topics[3].Bytes()),
+			TokenID: new(big.Int).SetBytes(l.Data[:32]),
+			Value:   new(big.Int).SetBytes(l.Data[32:]),
+		}}, nil
+	case standard == TokenStandardERC1155 && len(l.Topics) == 4 && l.Topics[0] == transferBatchEventIdentifier:
+		uint256Array, err := abi.NewType("uint256[]", "", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		values, err := abi.Arguments{{Type: uint256Array}, {Type: uint256Array}}.Unpack(l.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		ids, amounts := values[0].([]*big.Int), values[1].([]*big.Int)
+		if len(ids) != len(amounts) {
+			return nil, fmt.Errorf("TransferBatch has %d ids and %d values", len(ids), len(amounts))
+		}
+
+		transfers := make([]*tokenTransfer, 0, len(ids))
+		for i := range ids {
+			transfers = append(transfers, &tokenTransfer{
+				From:    common.BytesToAddress(l.Topics[2].Bytes()),
+				To:      common.BytesToAddress(l.Topics[3].Bytes()),
+				TokenID: ids[i],
+				Value:   amounts[i],
+			})
+		}
+
+		return transfers, nil
+	default:
+		return nil, nil
+	}
+}
+
+// parseTokenID accept the token id as a decimal or 0x prefixed hex string, an integer or a *big.Int
+func parseTokenID(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case string:
+		id, ok := new(big.Int).SetString(v, 0)
+		if !ok {
+			return nil, fmt.Errorf("token id %s is invalid", v)
+		}
+
+		return id, nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("token id %v is invalid", v)
+		}
+
+		return big.NewInt(int64(v)), nil
+	default:
+		return nil, fmt.Errorf("%s option is missing", TokenIDOption)
+	}
+}
+
+// createNFTTransaction send the token_id of tx options with safeTransferFrom,
+// the amount is ignored for erc721 tokens
+func (w *Wallet) createNFTTransaction(ctx context.Context, tx *transaction.Transaction, opt map[string]interface{}) (*transaction.Transaction, error) {
+	options := w.mergeOptions(defaultErc20Fee, w.currency.Options, tx.Options, opt)
+
+	value := tx.Options[TokenIDOption]
+	if v, ok := opt[TokenIDOption]; ok {
+		value = v
+	}
+
+	tokenID, err := parseTokenID(value)
+	if err != nil {
+		return nil, err
+	}
+
+	gasFee, err := w.suggestFee(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAddress, standard := contractOf(w.currency)
+	to := common.HexToAddress(w.normalizeAddress(contractAddress))
+	fromAddress := common.HexToAddress(w.normalizeAddress(w.wallet.Address))
+	toAddress := common.HexToAddress(w.normalizeAddress(tx.ToAddress))
+
+	var data []byte
+	if standard == TokenStandardERC721 {
+		data = append(common.CopyBytes(erc721SafeTransferFromMethodID), common.LeftPadBytes(fromAddress.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(toAddress.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(tokenID.Bytes(), 32)...)
+	} else {
+		amount := w.ConvertToBaseUnit(tx.Amount).BigInt()
+
+		data = append(common.CopyBytes(erc1155SafeTransferFromMethodID), common.LeftPadBytes(fromAddress.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(toAddress.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(tokenID.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+		// empty bytes argument: offset then length
+		data = append(data, common.LeftPadBytes(big.NewInt(5*32).Bytes(), 32)...)
+		data = append(data, make([]byte, 32)...)
+	}
+
+	gasLimit, err := w.gasLimit(ctx, options, ethereum.CallMsg{
+		From: fromAddress,
+		To:   &to,
+		Data: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx, err := w.sendTransaction(ctx, to, nil, data, gasLimit, gasFee)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.Options == nil {
+		tx.Options = make(map[string]interface{})
+	}
+
+	tx.Options[TokenIDOption] = tokenID.String()
+	w.setFee(tx, gasFee.Cost(gasLimit))
+	tx.Status = transaction.StatusPending
+	tx.TxHash = null.StringFrom(signedTx.Hash().Hex())
+
+	return tx, nil
+}
+
+func isNFT(standard TokenStandard) bool {
+	return standard == TokenStandardERC721 || standard == TokenStandardERC1155
+}
+
+func (b *Blockchain) buildTokenTransactions(tx *types.Transaction, l *types.Log, fee decimal.NullDecimal, status transaction.Status) ([]*transaction.Transaction, error) {
+	c := b.findContract(l.Address)
+	if c == nil {
+		return nil, nil
+	}
+
+	_, standard := contractOf(c)
+	transfers, err := decodeTokenTransfers(standard, l)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]*transaction.Transaction, 0, len(transfers))
+	for _, t := range transfers {
+		trans := &transaction.Transaction{
+			Currency:    c.ID,
+			CurrencyFee: b.currency.ID,
+			TxHash:      null.StringFrom(tx.Hash().Hex()),
+			FromAddress: t.From.Hex(),
+			ToAddress:   t.To.Hex(),
+			TxOut:       l.Index,
+			Fee:         fee,
+			Amount:      decimal.NewFromBigInt(t.Value, -c.Subunits),
+			Status:      status,
+		}
+
+		if t.TokenID != nil {
+			trans.Options = map[string]interface{}{
+				TokenIDOption: t.TokenID.String(),
+			}
+		}
+
+		transactions = append(transactions, trans)
+	}
+
+	return transactions, nil
+}
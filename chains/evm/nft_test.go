@@ -0,0 +1,63 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDecodeTokenTransfers(t *testing.T) {
+	operator := common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001")
+	from := common.HexToHash("0x000000000000000000000000becfbfd1baddb4fe857ccb428555b9ea47ffcc40")
+	to := common.HexToHash("0x000000000000000000000000f37111de2f6ae2f64be1e59472b5c50801540c8c")
+	word := func(i int64) []byte { return common.LeftPadBytes(big.NewInt(i).Bytes(), 32) }
+
+	erc721 := &types.Log{Topics: []common.Hash{common.HexToHash(tokenEventIdentifier), from, to, common.BigToHash(big.NewInt(42))}}
+	transfers, err := decodeTokenTransfers(TokenStandardERC721, erc721)
+	if err != nil || len(transfers) != 1 || transfers[0].TokenID.Int64() != 42 || transfers[0].Value.Int64() != 1 || transfers[0].To != common.BytesToAddress(to.Bytes()) {
+		t.Fatalf("unexpected erc721 transfers %+v (%v)", transfers, err)
+	}
+
+	// an erc721 Transfer is not an erc20 one
+	if transfers, _ := decodeTokenTransfers(TokenStandardERC20, erc721); len(transfers) != 0 {
+		t.Fatalf("expected no erc20 transfer, got %+v", transfers)
+	}
+
+	single := &types.Log{
+		Topics: []common.Hash{transferSingleEventIdentifier, operator, from, to},
+		Data:   append(word(7), word(3)...),
+	}
+	transfers, err = decodeTokenTransfers(TokenStandardERC1155, single)
+	if err != nil || len(transfers) != 1 || transfers[0].TokenID.Int64() != 7 || transfers[0].Value.Int64() != 3 || transfers[0].From != common.BytesToAddress(from.Bytes()) {
+		t.Fatalf("unexpected TransferSingle transfers %+v (%v)", transfers, err)
+	}
+
+	// ids at offset 0x40 and values at offset 0xa0
+	data := append(word(0x40), word(0xa0)...)
+	data = append(data, word(2)...)
+	data = append(data, word(1)...)
+	data = append(data, word(2)...)
+	data = append(data, word(2)...)
+	data = append(data, word(10)...)
+	data = append(data, word(20)...)
+
+	batch := &types.Log{Topics: []common.Hash{transferBatchEventIdentifier, operator, from, to}, Data: data}
+	transfers, err = decodeTokenTransfers(TokenStandardERC1155, batch)
+	if err != nil || len(transfers) != 2 || transfers[1].TokenID.Int64() != 2 || transfers[1].Value.Int64() != 20 {
+		t.Fatalf("unexpected TransferBatch transfers %+v (%v)", transfers, err)
+	}
+}
+
+func TestParseTokenID(t *testing.T) {
+	for _, value := range []interface{}{"255", "0xff", 255, int64(255), float64(255), big.NewInt(255)} {
+		if id, err := parseTokenID(value); err != nil || id.Int64() != 255 {
+			t.Errorf("expected %v to be parsed as 255, got %v (%v)", value, id, err)
+		}
+	}
+
+	if _, err := parseTokenID(nil); err == nil {
+		t.Error("expected an error for a missing token id")
+	}
+}
@@ -0,0 +1,197 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultNonceManager is shared by every evm wallet so the wallets of different
+// currencies using the same hot wallet address never hand out the same nonce
+var DefaultNonceManager = NewNonceManager(nil)
+
+// NonceStore persist the next nonce of addresses, it lets a restarted process
+// detect the transactions dropped by the node while it was down
+type NonceStore interface {
+	// Load return ok false when nothing was saved for address
+	Load(ctx context.Context, chainID *big.Int, address common.Address) (nonce uint64, ok bool, err error)
+	Save(ctx context.Context, chainID *big.Int, address common.Address, nonce uint64) error
+}
+
+type nonceSource interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// NonceGap is reported when the node doesn't know nonces which were handed out,
+// the transactions using them were dropped and the nonces are handed out again
+type NonceGap struct {
+	ChainID *big.Int
+	Address common.Address
+	From    uint64 // first missing nonce
+	To      uint64 // last missing nonce
+}
+
+func (g NonceGap) String() string {
+	return fmt.Sprintf("nonce gap %d-%d for %s on chain %s", g.From, g.To, g.Address.Hex(), g.ChainID)
+}
+
+// NonceManager hand out sequential nonces per address, it syncs with the node
+// on first use and after Reset
+type NonceManager struct {
+	// OnGap is called when a sync finds a gap, it must not call the manager
+	OnGap func(gap NonceGap)
+
+	mu       sync.Mutex
+	store    NonceStore
+	accounts map[string]*nonceAccount
+}
+
+type nonceAccount struct {
+	mu       sync.Mutex
+	synced   bool
+	next     uint64
+	released []uint64 // nonces handed out but never broadcast, sorted
+}
+
+// NewNonceManager create a nonce manager, store is optional
+func NewNonceManager(store NonceStore) *NonceManager {
+	return &NonceManager{
+		store:    store,
+		accounts: make(map[string]*nonceAccount),
+	}
+}
+
+func (m *NonceManager) account(chainID *big.Int, address common.Address) *nonceAccount {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := chainID.String() + ":" + address.Hex()
+	a, ok := m.accounts[key]
+	if !ok {
+		a = new(nonceAccount)
+		m.accounts[key] = a
+	}
+
+	return a
+}
+
+// Acquire return the next nonce to use for address, it must be given back
+// with Release when the transaction could not be broadcast
+func (m *NonceManager) Acquire(ctx context.Context, client nonceSource, chainID *big.Int, address common.Address) (uint64, error) {
+	a := m.account(chainID, address)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.synced {
+		if err := m.sync(ctx, a, client, chainID, address); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(a.released) > 0 {
+		nonce := a.released[0]
+		a.released = a.released[1:]
+
+		return nonce, nil
+	}
+
+	// the nonce is only handed out once it is saved, otherwise it would be a gap
+	if err := m.save(ctx, chainID, address, a.next+1); err != nil {
+		return 0, err
+	}
+
+	nonce := a.next
+	a.next++
+
+	return nonce, nil
+}
+
+// Release give back a nonce whose transaction was rejected, it is handed out
+// again before any new nonce so no gap is left
+func (m *NonceManager) Release(ctx context.Context, chainID *big.Int, address common.Address, nonce uint64) error {
+	a := m.account(chainID, address)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.synced || nonce >= a.next {
+		return nil
+	}
+
+	if nonce == a.next-1 {
+		if err := m.save(ctx, chainID, address, nonce); err != nil {
+			return err
+		}
+
+		a.next--
+
+		return nil
+	}
+
+	i := sort.Search(len(a.released), func(i int) bool { return a.released[i] >= nonce })
+	if i < len(a.released) && a.released[i] == nonce {
+		return nil
+	}
+
+	a.released = append(a.released, 0)
+	copy(a.released[i+1:], a.released[i:])
+	a.released[i] = nonce
+
+	return nil
+}
+
+// Reset make the next Acquire reconcile with the node, it is used when the node
+// rejected a nonce as too low or when it is unknown whether a transaction was broadcast
+func (m *NonceManager) Reset(chainID *big.Int, address common.Address) {
+	a := m.account(chainID, address)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.synced = false
+}
+
+// sync set the next nonce from the node pending nonce, stored and in memory nonces
+// ahead of the node are gaps which are handed out again
+func (m *NonceManager) sync(ctx context.Context, a *nonceAccount, client nonceSource, chainID *big.Int, address common.Address) error {
+	pending, err := client.PendingNonceAt(ctx, address)
+	if err != nil {
+		return normalizeError(err, nil)
+	}
+
+	next := a.next
+	if m.store != nil && next == 0 {
+		stored, ok, err := m.store.Load(ctx, chainID, address)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			next = stored
+		}
+	}
+
+	if next > pending && m.OnGap != nil {
+		m.OnGap(NonceGap{ChainID: chainID, Address: address, From: pending, To: next - 1})
+	}
+
+	a.next = pending
+	a.released = nil
+	a.synced = true
+
+	return nil
+}
+
+// save persist next as the next nonce of address
+func (m *NonceManager) save(ctx context.Context, chainID *big.Int, address common.Address, next uint64) error {
+	if m.store == nil {
+		return nil
+	}
+
+	return m.store.Save(ctx, chainID, address, next)
+}
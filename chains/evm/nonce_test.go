@@ -0,0 +1,140 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type fakeNonceSource struct {
+	pending uint64
+}
+
+func (s *fakeNonceSource) PendingNonceAt(_ context.Context, _ common.Address) (uint64, error) {
+	return s.pending, nil
+}
+
+func TestNonceManager(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(1)
+	address := common.HexToAddress("0xf37111de2f6ae2f64be1e59472b5c50801540c8c")
+	source := &fakeNonceSource{pending: 7}
+
+	var gaps []NonceGap
+	m := NewNonceManager(nil)
+	m.OnGap = func(gap NonceGap) { gaps = append(gaps, gap) }
+
+	var wg sync.WaitGroup
+	nonces := make(chan uint64, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			nonce, err := m.Acquire(ctx, source, chainID, address)
+			if err != nil {
+				t.Error(err)
+			}
+
+			nonces <- nonce
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+
+	seen := make(map[uint64]bool)
+	for nonce := range nonces {
+		if nonce < 7 || nonce > 16 || seen[nonce] {
+			t.Fatalf("unexpected nonce %d", nonce)
+		}
+		seen[nonce] = true
+	}
+
+	// a rejected nonce in the middle is handed out before new ones
+	m.Release(ctx, chainID, address, 9)
+	if nonce, _ := m.Acquire(ctx, source, chainID, address); nonce != 9 {
+		t.Fatalf("expected released nonce 9, got %d", nonce)
+	}
+
+	if nonce, _ := m.Acquire(ctx, source, chainID, address); nonce != 17 {
+		t.Fatalf("expected nonce 17, got %d", nonce)
+	}
+
+	// the node lost the transactions after 12
+	source.pending = 12
+	m.Reset(chainID, address)
+	if nonce, _ := m.Acquire(ctx, source, chainID, address); nonce != 12 {
+		t.Fatalf("expected nonce 12 after sync, got %d", nonce)
+	}
+
+	if len(gaps) != 1 || gaps[0].From != 12 || gaps[0].To != 17 {
+		t.Fatalf("expected gap 12-17, got %v", gaps)
+	}
+
+	// other chains have their own nonces
+	if nonce, _ := m.Acquire(ctx, source, big.NewInt(56), address); nonce != 12 {
+		t.Fatalf("expected nonce 12 on another chain, got %d", nonce)
+	}
+}
+
+type failingNonceStore struct {
+	fail  bool
+	saved uint64
+}
+
+func (s *failingNonceStore) Load(context.Context, *big.Int, common.Address) (uint64, bool, error) {
+	return 0, false, nil
+}
+
+func (s *failingNonceStore) Save(_ context.Context, _ *big.Int, _ common.Address, nonce uint64) error {
+	if s.fail {
+		return errors.New("store is down")
+	}
+
+	s.saved = nonce
+
+	return nil
+}
+
+func TestNonceManager_SaveFailure(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(1)
+	address := common.HexToAddress("0xf37111de2f6ae2f64be1e59472b5c50801540c8c")
+	source := &fakeNonceSource{pending: 3}
+	store := &failingNonceStore{}
+
+	m := NewNonceManager(store)
+	if nonce, err := m.Acquire(ctx, source, chainID, address); err != nil || nonce != 3 {
+		t.Fatalf("expected nonce 3, got %d (%v)", nonce, err)
+	}
+
+	// a nonce which can't be saved is not handed out and is not skipped
+	store.fail = true
+	if _, err := m.Acquire(ctx, source, chainID, address); err == nil {
+		t.Fatal("expected the save failure to be returned")
+	}
+
+	store.fail = false
+	if nonce, err := m.Acquire(ctx, source, chainID, address); err != nil || nonce != 4 {
+		t.Fatalf("expected nonce 4 after the failure, got %d (%v)", nonce, err)
+	}
+
+	if store.saved != 5 {
+		t.Fatalf("expected next nonce 5 to be saved, got %d", store.saved)
+	}
+
+	// a release which can't be saved keeps the nonce used
+	store.fail = true
+	if err := m.Release(ctx, chainID, address, 4); err == nil {
+		t.Fatal("expected the save failure to be returned")
+	}
+
+	store.fail = false
+	if nonce, _ := m.Acquire(ctx, source, chainID, address); nonce != 5 {
+		t.Fatalf("expected nonce 5, got %d", nonce)
+	}
+}
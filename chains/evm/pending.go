@@ -0,0 +1,54 @@
+package evm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/volatiletech/null/v9"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+// buildPendingTransactions build the transactions of tx before it is mined, without a receipt
+// the fee is unknown and token transfers are decoded from the call data instead of the logs
+func (b *Blockchain) buildPendingTransactions(tx *types.Transaction) ([]*transaction.Transaction, error) {
+	if tx.To() == nil {
+		return []*transaction.Transaction{}, nil
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, err
+	}
+
+	trans := &transaction.Transaction{
+		Currency:      b.currency.ID,
+		CurrencyFee:   b.currency.ID,
+		TxHash:        null.StringFrom(tx.Hash().Hex()),
+		FromAddress:   from.Hex(),
+		ToAddress:     tx.To().Hex(),
+		Amount:        decimal.NewFromBigInt(tx.Value(), -b.currency.Subunits),
+		Status:        transaction.StatusPending,
+		FirstSeen:     null.TimeFrom(b.pending.FirstSeen(tx.Hash().Hex())),
+		Confirmations: null.Int64From(0),
+	}
+
+	if c := b.findContract(*tx.To()); c != nil {
+		parties, ok := tokenTransferParties(tx.Data())
+		if _, standard := contractOf(c); !ok || standard != TokenStandardERC20 {
+			// other calls only move tokens through their logs
+			return []*transaction.Transaction{}, nil
+		}
+
+		if len(parties) == 2 {
+			trans.FromAddress = parties[0].Hex()
+		}
+
+		data := tx.Data()
+		trans.Currency = c.ID
+		trans.ToAddress = parties[len(parties)-1].Hex()
+		trans.Amount = decimal.NewFromBigInt(new(big.Int).SetBytes(data[len(data)-32:]), -c.Subunits)
+	}
+
+	return []*transaction.Transaction{trans}, nil
+}
@@ -0,0 +1,177 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zsmartex/multichain/pkg/blockchain"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+// newPendingServer fake a node which has tx in its mempool, or knows nothing when tx is nil
+func newPendingServer(t *testing.T, tx *types.Transaction) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+
+		if msg.Method != "eth_getTransactionByHash" {
+			t.Errorf("unexpected method %s", msg.Method)
+		}
+
+		var result interface{}
+		if tx != nil {
+			data, err := tx.MarshalJSON()
+			if err != nil {
+				t.Error(err)
+			}
+
+			var fields map[string]interface{}
+			json.Unmarshal(data, &fields)
+			fields["blockHash"] = nil
+			fields["blockNumber"] = nil
+			result = fields
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+	}))
+}
+
+func TestBlockchain_GetPendingTransaction(t *testing.T) {
+	key, err := crypto.HexToECDSA("5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	recipient := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	usdt := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+
+	transfer, err := erc20ABI.Pack("transfer", recipient, big.NewInt(5e6))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range map[string]struct {
+		to       common.Address
+		value    *big.Int
+		data     []byte
+		currency string
+		amount   string
+	}{
+		"native": {to: recipient, value: big.NewInt(1e18), currency: "eth", amount: "1"},
+		"token":  {to: usdt, value: big.NewInt(0), data: transfer, currency: "usdt", amount: "5"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+				ChainID:   big.NewInt(1),
+				Gas:       21000,
+				GasFeeCap: big.NewInt(1e9),
+				GasTipCap: big.NewInt(1e9),
+				To:        &test.to,
+				Value:     test.value,
+				Data:      test.data,
+			}), types.LatestSignerForChainID(big.NewInt(1)), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server := newPendingServer(t, tx)
+			defer server.Close()
+
+			b := newPendingBlockchain(t, server.URL)
+			defer b.Close()
+
+			transactions, err := b.GetTransaction(context.Background(), tx.Hash().Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(transactions) != 1 {
+				t.Fatalf("expected 1 transaction, got %d", len(transactions))
+			}
+
+			trans := transactions[0]
+			if trans.Status != transaction.StatusPending || !trans.FirstSeen.Valid {
+				t.Fatalf("expected a pending transaction with a first seen time, got %s", trans.Status)
+			}
+
+			if trans.Currency != test.currency || trans.Amount.String() != test.amount {
+				t.Fatalf("unexpected transfer of %s %s", trans.Amount, trans.Currency)
+			}
+
+			if trans.FromAddress != sender.Hex() || trans.ToAddress != recipient.Hex() {
+				t.Fatalf("unexpected transfer from %s to %s", trans.FromAddress, trans.ToAddress)
+			}
+
+			again, err := b.GetTransaction(context.Background(), tx.Hash().Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !again[0].FirstSeen.Time.Equal(trans.FirstSeen.Time) {
+				t.Fatal("expected the first seen time to be kept")
+			}
+
+			confirmations, err := b.GetConfirmations(context.Background(), tx.Hash().Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if confirmations != 0 || trans.Confirmations.Int64 != 0 || !trans.Confirmations.Valid {
+				t.Fatal("expected a pending transaction to have no confirmation")
+			}
+		})
+	}
+}
+
+func TestBlockchain_GetDroppedTransaction(t *testing.T) {
+	server := newPendingServer(t, nil)
+	defer server.Close()
+
+	b := newPendingBlockchain(t, server.URL)
+	defer b.Close()
+
+	hash := common.HexToHash("0x01")
+
+	_, err := b.GetTransaction(context.Background(), hash.Hex())
+	if !errors.Is(err, errors.ErrTransactionNotFound) || errors.Is(err, errors.ErrTransactionDropped) {
+		t.Fatalf("expected a transaction never seen to be not found, got %v", err)
+	}
+
+	// the transaction was in the mempool before the node forgot it
+	b.pending.FirstSeen(hash.Hex())
+
+	for i := 0; i < 2; i++ {
+		_, err = b.GetTransaction(context.Background(), hash.Hex())
+		if !errors.Is(err, errors.ErrTransactionDropped) || !errors.Is(err, errors.ErrTransactionNotFound) {
+			t.Fatalf("expected a dropped transaction, got %v", err)
+		}
+	}
+}
+
+func newPendingBlockchain(t *testing.T, uri string) *Blockchain {
+	b := NewBlockchain().(*Blockchain)
+	if err := b.Configure(&blockchain.Setting{
+		URI: uri,
+		Currencies: []*currency.Currency{
+			{ID: "eth", Subunits: 18},
+			{ID: "usdt", Subunits: 6, Options: map[string]interface{}{"erc20_contract_address": "0xdAC17F958D2ee523a2206206994597C13D831ec7"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
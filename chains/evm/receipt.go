@@ -0,0 +1,72 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// txReceipt is a types.Receipt with the fields go-ethereum v1.10 doesn't decode,
+// the effective gas price of EIP-1559 and the L1 data fee of OP stack rollups
+type txReceipt struct {
+	*types.Receipt
+	EffectiveGasPrice *big.Int
+	L1Fee             *big.Int
+}
+
+func (r *txReceipt) UnmarshalJSON(input []byte) error {
+	var receipt types.Receipt
+	if err := json.Unmarshal(input, &receipt); err != nil {
+		return err
+	}
+
+	var extra struct {
+		EffectiveGasPrice *hexutil.Big `json:"effectiveGasPrice"`
+		L1Fee             *hexutil.Big `json:"l1Fee"`
+	}
+	if err := json.Unmarshal(input, &extra); err != nil {
+		return err
+	}
+
+	r.Receipt = &receipt
+	r.EffectiveGasPrice = (*big.Int)(extra.EffectiveGasPrice)
+	r.L1Fee = (*big.Int)(extra.L1Fee)
+
+	return nil
+}
+
+func (b *Blockchain) getReceipt(ctx context.Context, txHash common.Hash) (*txReceipt, error) {
+	var receipt *txReceipt
+	if err := b.rpcClient.CallContext(ctx, &receipt, "eth_getTransactionReceipt", txHash); err != nil {
+		return nil, normalizeError(err, errors.ErrTransactionNotFound)
+	}
+
+	if receipt == nil {
+		return nil, fmt.Errorf("%w: %s", errors.ErrTransactionNotFound, txHash.Hex())
+	}
+
+	return receipt, nil
+}
+
+// transactionFee is what the sender paid for tx: the gas used at the effective gas price
+// plus the L1 data fee on rollups, nodes without effectiveGasPrice are charged at the gas price cap
+func (b *Blockchain) transactionFee(tx *types.Transaction, receipt *txReceipt) decimal.Decimal {
+	gasPrice := receipt.EffectiveGasPrice
+	if gasPrice == nil {
+		gasPrice = tx.GasPrice()
+	}
+
+	fee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+	if receipt.L1Fee != nil {
+		fee.Add(fee, receipt.L1Fee)
+	}
+
+	return decimal.NewFromBigInt(fee, -b.currency.Subunits)
+}
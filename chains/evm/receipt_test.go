@@ -0,0 +1,80 @@
+package evm
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+func TestTransactionFee(t *testing.T) {
+	var receipt *txReceipt
+	if err := json.Unmarshal([]byte(`{
+		"type": "0x2",
+		"status": "0x1",
+		"cumulativeGasUsed": "0x5208",
+		"logsBloom": "0x`+common.Bytes2Hex(make([]byte, 256))+`",
+		"logs": [],
+		"transactionHash": "0x0100000000000000000000000000000000000000000000000000000000000001",
+		"gasUsed": "0x5208",
+		"effectiveGasPrice": "0x3b9aca00",
+		"l1Fee": "0x2540be400"
+	}`), &receipt); err != nil {
+		t.Fatal(err)
+	}
+
+	if receipt.GasUsed != 21000 || receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("unexpected receipt %+v", receipt.Receipt)
+	}
+
+	b := &Blockchain{currency: &currency.Currency{ID: "eth", Subunits: 18}}
+	to := common.HexToAddress("0xf37111de2f6ae2f64be1e59472b5c50801540c8c")
+	tx := types.NewTx(&types.DynamicFeeTx{To: &to, Gas: 100000, GasFeeCap: big.NewInt(5_000_000_000), GasTipCap: big.NewInt(1)})
+
+	// 21000 gas at 1 gwei plus 10 gwei of l1 fee
+	if fee := b.transactionFee(tx, receipt); fee.String() != "0.00002101" {
+		t.Fatalf("unexpected fee %s", fee)
+	}
+
+	receipt.EffectiveGasPrice, receipt.L1Fee = nil, nil
+	if fee := b.transactionFee(tx, receipt); fee.String() != "0.000105" {
+		t.Fatalf("expected fee at the gas price cap, got %s", fee)
+	}
+}
+
+func TestBuildTransactionWithReceipt_FailedTokenCall(t *testing.T) {
+	usdt := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	recipient := common.HexToAddress("0x4444444444444444444444444444444444444444")
+
+	b := &Blockchain{
+		currency:  &currency.Currency{ID: "eth", Subunits: 18},
+		contracts: []*currency.Currency{{ID: "usdt", Subunits: 6, Options: map[string]interface{}{"erc20_contract_address": usdt.Hex()}}},
+	}
+
+	data, err := erc20ABI.Pack("transfer", recipient, big.NewInt(5e6))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), To: &usdt, Gas: 100000, GasFeeCap: big.NewInt(1e9), Data: data})
+	receipt := &txReceipt{Receipt: &types.Receipt{Status: types.ReceiptStatusFailed, GasUsed: 50000, BlockNumber: big.NewInt(100)}}
+
+	transactions, err := b.buildTransactionWithReceipt(tx, receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(transactions))
+	}
+
+	// the failed call is a token transfer rather than a 0 value native one
+	trans := transactions[0]
+	if trans.Currency != "usdt" || trans.ToAddress != recipient.Hex() || trans.Status != transaction.StatusFailed || !trans.Fee.Valid {
+		t.Fatalf("unexpected transaction %+v", trans)
+	}
+}
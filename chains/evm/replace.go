@@ -0,0 +1,159 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/shopspring/decimal"
+	"github.com/volatiletech/null/v9"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+// ReplacedTxHashOption is the transaction option holding the hash of the transaction a replacement replaces
+const ReplacedTxHashOption = "replaced_tx_hash"
+
+// priceBump is the minimum fee increase in percent nodes require to replace a pending transaction
+const priceBump = 10
+
+// SpeedUp rebroadcast a pending transaction of the wallet with the same nonce and bumped fees
+func (w *Wallet) SpeedUp(ctx context.Context, txHash string) (*transaction.Transaction, error) {
+	original, err := w.pendingTransaction(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.replaceTransaction(ctx, original, *original.To(), original.Value(), original.Data(), original.Gas())
+}
+
+// Cancel replace a pending transaction of the wallet with an empty transfer to itself
+func (w *Wallet) Cancel(ctx context.Context, txHash string) (*transaction.Transaction, error) {
+	original, err := w.pendingTransaction(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	self := common.HexToAddress(w.normalizeAddress(w.wallet.Address))
+
+	return w.replaceTransaction(ctx, original, self, new(big.Int), nil, params.TxGas)
+}
+
+func (w *Wallet) pendingTransaction(ctx context.Context, txHash string) (*types.Transaction, error) {
+	tx, isPending, err := w.client.TransactionByHash(ctx, common.HexToHash(txHash))
+	if err != nil {
+		return nil, normalizeError(err, errors.ErrTransactionNotFound)
+	}
+
+	if !isPending {
+		return nil, fmt.Errorf("%w: %s", errors.ErrTransactionNotPending, txHash)
+	}
+
+	if tx.To() == nil {
+		return nil, fmt.Errorf("transaction %s is a contract creation", txHash)
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if sender != common.HexToAddress(w.normalizeAddress(w.wallet.Address)) {
+		return nil, fmt.Errorf("transaction %s is not sent by %s", txHash, w.wallet.Address)
+	}
+
+	return tx, nil
+}
+
+func (w *Wallet) replaceTransaction(ctx context.Context, original *types.Transaction, to common.Address, value *big.Int, data []byte, gasLimit uint64) (*transaction.Transaction, error) {
+	options := w.mergeOptions(defaultEvmFee, w.currency.Options)
+
+	// replacements keep the type of the original transaction
+	options.TxType = TxTypeLegacy
+	if original.Type() == types.DynamicFeeTxType {
+		options.TxType = TxTypeDynamicFee
+	}
+
+	suggested, err := w.suggestFee(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := bumpFee(original, suggested)
+
+	privateKey, err := w.privateKey(w.wallet.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := w.client.ChainID(ctx)
+	if err != nil {
+		return nil, normalizeError(err, nil)
+	}
+
+	signedTx, err := types.SignNewTx(privateKey, types.LatestSignerForChainID(chainID), newTxData(chainID, original.Nonce(), to, value, data, gasLimit, fee))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.client.SendTransaction(ctx, signedTx); err != nil {
+		return nil, normalizeError(err, nil)
+	}
+
+	// the replacement moves what it replaces, a token of the wallet or else the native currency
+	c, toAddress := w.nativeCurrency(), to.Hex()
+	amount := decimal.NewFromBigInt(value, -c.Subunits)
+	if parties, ok := tokenTransferParties(data); ok && strings.EqualFold(to.Hex(), w.ContractAddress()) {
+		c, toAddress = w.currency, parties[len(parties)-1].Hex()
+		amount = decimal.NewFromBigInt(new(big.Int).SetBytes(data[len(data)-32:]), -c.Subunits)
+	}
+
+	tx := &transaction.Transaction{
+		Currency:    c.ID,
+		FromAddress: w.wallet.Address,
+		ToAddress:   toAddress,
+		Amount:      amount,
+		TxHash:      null.StringFrom(signedTx.Hash().Hex()),
+		Status:      transaction.StatusPending,
+		Options: map[string]interface{}{
+			ReplacedTxHashOption: original.Hash().Hex(),
+		},
+	}
+	w.setFee(tx, fee.Cost(gasLimit))
+
+	return tx, nil
+}
+
+// bumpFee return the highest of the suggested fee and the original fee raised by priceBump
+func bumpFee(original *types.Transaction, suggested *gasFee) *gasFee {
+	if original.Type() == types.DynamicFeeTxType {
+		tip := maxBig(bumpPrice(original.GasTipCap()), suggested.GasTipCap)
+		feeCap := maxBig(bumpPrice(original.GasFeeCap()), suggested.GasFeeCap)
+		if feeCap.Cmp(tip) < 0 {
+			feeCap = tip
+		}
+
+		return &gasFee{Dynamic: true, GasFeeCap: feeCap, GasTipCap: tip}
+	}
+
+	return &gasFee{GasPrice: maxBig(bumpPrice(original.GasPrice()), suggested.GasPrice)}
+}
+
+func bumpPrice(price *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(price, big.NewInt(100+priceBump))
+	bumped.Div(bumped, big.NewInt(100))
+
+	return bumped.Add(bumped, big.NewInt(1))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if b == nil || a.Cmp(b) >= 0 {
+		return a
+	}
+
+	return b
+}
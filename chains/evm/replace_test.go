@@ -0,0 +1,158 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+func TestBumpFee(t *testing.T) {
+	to := common.HexToAddress("0xf37111de2f6ae2f64be1e59472b5c50801540c8c")
+
+	legacy := types.NewTx(&types.LegacyTx{To: &to, GasPrice: big.NewInt(100)})
+	if fee := bumpFee(legacy, &gasFee{GasPrice: big.NewInt(50)}); fee.Dynamic || fee.GasPrice.Int64() != 111 {
+		t.Errorf("expected legacy gas price of 111, got %+v", fee)
+	}
+
+	if fee := bumpFee(legacy, &gasFee{GasPrice: big.NewInt(200)}); fee.GasPrice.Int64() != 200 {
+		t.Errorf("expected suggested gas price of 200, got %+v", fee)
+	}
+
+	dynamic := types.NewTx(&types.DynamicFeeTx{To: &to, GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(100)})
+	fee := bumpFee(dynamic, &gasFee{Dynamic: true, GasTipCap: big.NewInt(20), GasFeeCap: big.NewInt(90)})
+	if !fee.Dynamic || fee.GasTipCap.Int64() != 20 || fee.GasFeeCap.Int64() != 111 {
+		t.Errorf("expected tip 20 and fee cap 111, got %+v", fee)
+	}
+}
+
+// newReplaceServer fake a node which has tx in its mempool and accepts its replacements
+func newReplaceServer(t *testing.T, tx *types.Transaction) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+
+		var result interface{}
+		switch msg.Method {
+		case "eth_getTransactionByHash":
+			data, err := tx.MarshalJSON()
+			if err != nil {
+				t.Error(err)
+			}
+
+			var fields map[string]interface{}
+			json.Unmarshal(data, &fields)
+			fields["blockHash"] = nil
+			fields["blockNumber"] = nil
+			result = fields
+		case "eth_gasPrice":
+			result = "0x3b9aca00"
+		case "eth_chainId":
+			result = "0x1"
+		case "eth_sendRawTransaction":
+			result = common.Hash{}.Hex()
+		default:
+			t.Errorf("unexpected method %s", msg.Method)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+	}))
+}
+
+func TestWallet_Replace(t *testing.T) {
+	key, err := crypto.HexToECDSA("5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	recipient := common.HexToAddress("0x4444444444444444444444444444444444444444")
+	usdt := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+
+	transfer, err := erc20ABI.Pack("transfer", recipient, big.NewInt(5e6))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, test := range map[string]struct {
+		to       common.Address
+		value    *big.Int
+		data     []byte
+		cancel   bool
+		currency string
+		amount   string
+		address  common.Address
+	}{
+		"speed up token":  {to: usdt, value: big.NewInt(0), data: transfer, currency: "usdt", amount: "5", address: recipient},
+		"speed up native": {to: recipient, value: big.NewInt(1e18), currency: "eth", amount: "1", address: recipient},
+		"cancel token":    {to: usdt, value: big.NewInt(0), data: transfer, cancel: true, currency: "eth", amount: "0", address: sender},
+		"cancel native":   {to: recipient, value: big.NewInt(1e18), cancel: true, currency: "eth", amount: "0", address: sender},
+	} {
+		t.Run(name, func(t *testing.T) {
+			original, err := types.SignTx(types.NewTx(&types.LegacyTx{
+				Nonce:    3,
+				Gas:      60000,
+				GasPrice: big.NewInt(1e9),
+				To:       &test.to,
+				Value:    test.value,
+				Data:     test.data,
+			}), types.LatestSignerForChainID(big.NewInt(1)), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server := newReplaceServer(t, original)
+			defer server.Close()
+
+			w := NewWallet().(*Wallet)
+			if err := w.Configure(&wallet.Setting{
+				Wallet: &wallet.SettingWallet{
+					URI:     server.URL,
+					Address: sender.Hex(),
+					Secret:  "0x5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515",
+				},
+				Currency: &currency.Currency{ID: "usdt", Subunits: 6, Options: map[string]interface{}{
+					"erc20_contract_address": usdt.Hex(),
+					"native_currency":        "eth",
+				}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			replace := w.SpeedUp
+			if test.cancel {
+				replace = w.Cancel
+			}
+
+			tx, err := replace(context.Background(), original.Hash().Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tx.Currency != test.currency || tx.Amount.String() != test.amount || tx.ToAddress != test.address.Hex() {
+				t.Fatalf("unexpected replacement of %s %s to %s", tx.Amount, tx.Currency, tx.ToAddress)
+			}
+
+			if tx.Status != transaction.StatusPending || tx.Options[ReplacedTxHashOption] != original.Hash().Hex() {
+				t.Fatalf("expected a pending replacement of %s, got %s of %v", original.Hash().Hex(), tx.Status, tx.Options[ReplacedTxHashOption])
+			}
+
+			if tx.CurrencyFee != "eth" || !tx.Fee.Valid || tx.Fee.Decimal.Sign() <= 0 {
+				t.Fatalf("expected a fee in eth, got %v %s", tx.Fee, tx.CurrencyFee)
+			}
+		})
+	}
+}
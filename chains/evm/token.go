@@ -0,0 +1,145 @@
+package evm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+var (
+	nameMethodID     = common.FromHex("0x06fdde03")
+	symbolMethodID   = common.FromHex("0x95d89b41")
+	decimalsMethodID = common.FromHex("0x313ce567")
+)
+
+type TokenMetadata struct {
+	Name     string
+	Symbol   string
+	Decimals uint8
+}
+
+// GetTokenMetadata read the name, symbol and decimals of an ERC20 contract, tokens returning
+// bytes32 for name and symbol (like MKR) are supported. Name and symbol are optional in ERC20,
+// they are empty when the contract doesn't implement them
+func (b *Blockchain) GetTokenMetadata(ctx context.Context, contractAddress string) (*TokenMetadata, error) {
+	if !common.IsHexAddress(contractAddress) {
+		return nil, fmt.Errorf("contract address %s is invalid", contractAddress)
+	}
+
+	contract := common.HexToAddress(contractAddress)
+
+	metadata := new(TokenMetadata)
+
+	var err error
+	if metadata.Name, err = b.callTokenString(ctx, contract, nameMethodID); err != nil {
+		return nil, err
+	}
+
+	if metadata.Symbol, err = b.callTokenString(ctx, contract, symbolMethodID); err != nil {
+		return nil, err
+	}
+
+	decimals, err := b.callContract(ctx, contract, decimalsMethodID)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata.Decimals, err = decodeTokenDecimals(decimals); err != nil {
+		return nil, fmt.Errorf("failed to decode decimals of %s: %v", contractAddress, err)
+	}
+
+	return metadata, nil
+}
+
+// Validate check that the subunits of every configured token match the decimals of its contract
+func (b *Blockchain) Validate(ctx context.Context) error {
+	for _, c := range b.contracts {
+		contractAddress, standard := contractOf(c)
+		if standard != TokenStandardERC20 {
+			continue
+		}
+
+		metadata, err := b.GetTokenMetadata(ctx, contractAddress)
+		if err != nil {
+			return err
+		}
+
+		if int64(metadata.Decimals) != int64(c.Subunits) {
+			return fmt.Errorf("%w: currency %s has %d subunits but contract %s (%s) has %d decimals", errors.ErrInvalidConfiguration, c.ID, c.Subunits, contractAddress, metadata.Symbol, metadata.Decimals)
+		}
+	}
+
+	return nil
+}
+
+func (b *Blockchain) callContract(ctx context.Context, contract common.Address, data []byte) ([]byte, error) {
+	result, err := b.client.CallContract(ctx, ethereum.CallMsg{
+		To:   &contract,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, normalizeError(err, nil)
+	}
+
+	return result, nil
+}
+
+// callTokenString read an optional string of the contract, the call reverting or returning
+// something else than a string reads as empty while errors of the node are returned
+func (b *Blockchain) callTokenString(ctx context.Context, contract common.Address, method []byte) (string, error) {
+	data, err := b.callContract(ctx, contract, method)
+	if err != nil {
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	value, err := decodeTokenString(data)
+	if err != nil {
+		return "", nil
+	}
+
+	return value, nil
+}
+
+func decodeTokenString(data []byte) (string, error) {
+	if len(data) == 32 {
+		return strings.TrimSpace(string(bytes.TrimRight(data, "\x00"))), nil
+	}
+
+	stringType, err := abi.NewType("string", "", nil)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := abi.Arguments{{Type: stringType}}.Unpack(data)
+	if err != nil {
+		return "", err
+	}
+
+	return values[0].(string), nil
+}
+
+func decodeTokenDecimals(data []byte) (uint8, error) {
+	if len(data) != 32 {
+		return 0, fmt.Errorf("unexpected result length %d", len(data))
+	}
+
+	decimals := new(big.Int).SetBytes(data)
+	if !decimals.IsUint64() || decimals.Uint64() > 255 {
+		return 0, fmt.Errorf("decimals %s is out of range", decimals)
+	}
+
+	return uint8(decimals.Uint64()), nil
+}
@@ -0,0 +1,88 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestDecodeTokenString(t *testing.T) {
+	// abi encoded "Tether USD"
+	encoded := common.FromHex("0x" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000000a" +
+		"5465746865722055534400000000000000000000000000000000000000000000")
+
+	if name, err := decodeTokenString(encoded); err != nil || name != "Tether USD" {
+		t.Errorf("expected Tether USD, got %q (%v)", name, err)
+	}
+
+	// bytes32 "MKR"
+	if symbol, err := decodeTokenString(common.RightPadBytes([]byte("MKR"), 32)); err != nil || symbol != "MKR" {
+		t.Errorf("expected MKR, got %q (%v)", symbol, err)
+	}
+
+	if _, err := decodeTokenString(nil); err == nil {
+		t.Error("expected an error for an empty result")
+	}
+}
+
+func TestDecodeTokenDecimals(t *testing.T) {
+	if decimals, err := decodeTokenDecimals(common.LeftPadBytes([]byte{6}, 32)); err != nil || decimals != 6 {
+		t.Errorf("expected 6 decimals, got %d (%v)", decimals, err)
+	}
+
+	if _, err := decodeTokenDecimals(common.LeftPadBytes([]byte{1, 0}, 32)); err == nil {
+		t.Error("expected an error for decimals above 255")
+	}
+}
+
+func TestBlockchain_GetTokenMetadata(t *testing.T) {
+	// a token without name, whose symbol() hits an empty fallback
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+
+		var args callArgs
+		json.Unmarshal(msg.Params[0], &args)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch common.Bytes2Hex(args.Data) {
+		case common.Bytes2Hex(nameMethodID):
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted"}}`))
+			return
+		case common.Bytes2Hex(symbolMethodID):
+			msg.Result = "0x"
+		case common.Bytes2Hex(decimalsMethodID):
+			msg.Result = hexutil.Bytes(common.LeftPadBytes([]byte{6}, 32))
+		default:
+			t.Errorf("unexpected call %x", args.Data)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": msg.Result})
+	}))
+	defer server.Close()
+
+	b := newPendingBlockchain(t, server.URL)
+	defer b.Close()
+
+	metadata, err := b.GetTokenMetadata(context.Background(), "0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Name != "" || metadata.Symbol != "" || metadata.Decimals != 6 {
+		t.Fatalf("unexpected metadata %+v", metadata)
+	}
+
+	if err := b.Validate(context.Background()); err != nil {
+		t.Fatalf("expected a token without name and symbol to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package evm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/volatiletech/null/v9"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/watchlist"
+)
+
+type TraceMode string
+
+const (
+	// TraceModeCallTracer use debug_traceBlockByHash with the geth callTracer
+	TraceModeCallTracer TraceMode = "call_tracer"
+	// TraceModeTraceBlock use the parity style trace_block of erigon, nethermind and openethereum
+	TraceModeTraceBlock TraceMode = "trace_block"
+)
+
+const (
+	// InternalTransferOption mark the transactions built from a value transfer made by a contract,
+	// their TxOut is InternalTxOutOffset plus the position of the transfer in the transaction
+	InternalTransferOption = "internal_transfer"
+	// TraceAddressOption is the position of the internal call in the call tree, like 0.2.1
+	TraceAddressOption = "trace_address"
+)
+
+// InternalTxOutOffset keep the TxOut of the internal transfers apart from the top-level
+// transfer, which is 0, and from the token transfers, which use their log index
+const InternalTxOutOffset uint = 1 << 24
+
+type internalTransfer struct {
+	TxHash       common.Hash
+	From         common.Address
+	To           common.Address
+	Value        *big.Int
+	TraceAddress []int
+}
+
+type callFrame struct {
+	Type  string         `json:"type"`
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Value *hexutil.Big   `json:"value"`
+	Error string         `json:"error"`
+	Calls []*callFrame   `json:"calls"`
+}
+
+type parityTrace struct {
+	Action struct {
+		CallType      string         `json:"callType"`
+		From          common.Address `json:"from"`
+		To            common.Address `json:"to"`
+		Value         *hexutil.Big   `json:"value"`
+		Address       common.Address `json:"address"`
+		RefundAddress common.Address `json:"refundAddress"`
+		Balance       *hexutil.Big   `json:"balance"`
+	} `json:"action"`
+	Result *struct {
+		Address common.Address `json:"address"`
+	} `json:"result"`
+	BlockHash       common.Hash  `json:"blockHash"`
+	TransactionHash *common.Hash `json:"transactionHash"`
+	TraceAddress    []int        `json:"traceAddress"`
+	Type            string       `json:"type"`
+	Error           string       `json:"error"`
+}
+
+func (b *Blockchain) buildInternalTransactions(ctx context.Context, result *types.Block) ([]*transaction.Transaction, error) {
+	var transfers []*internalTransfer
+	var err error
+
+	switch b.traceMode {
+	case TraceModeCallTracer:
+		transfers, err = b.callTracerTransfers(ctx, result)
+	case TraceModeTraceBlock:
+		transfers, err = b.traceBlockTransfers(ctx, result)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]*transaction.Transaction, 0)
+	positions := make(map[common.Hash]uint)
+	for _, t := range transfers {
+		position := positions[t.TxHash]
+		positions[t.TxHash]++
+
+		if b.watchlist != nil && !watchlist.ContainsAny(b.watchlist, t.From.Hex(), t.To.Hex()) {
+			continue
+		}
+
+		transactions = append(transactions, &transaction.Transaction{
+			Currency:    b.currency.ID,
+			CurrencyFee: b.currency.ID,
+			TxHash:      null.StringFrom(t.TxHash.Hex()),
+			FromAddress: t.From.Hex(),
+			ToAddress:   t.To.Hex(),
+			TxOut:       InternalTxOutOffset + position,
+			Amount:      decimal.NewFromBigInt(t.Value, -b.currency.Subunits),
+			BlockNumber: result.Number().Int64(),
+			Status:      transaction.StatusSucceed,
+			Options: map[string]interface{}{
+				InternalTransferOption: true,
+				TraceAddressOption:     formatTraceAddress(t.TraceAddress),
+			},
+		})
+	}
+
+	return transactions, nil
+}
+
+// callTracerTransfers trace the block by hash rather than by number so a reorg cannot mix two blocks
+func (b *Blockchain) callTracerTransfers(ctx context.Context, result *types.Block) ([]*internalTransfer, error) {
+	var traces []struct {
+		Result *callFrame `json:"result"`
+		Error  string     `json:"error"`
+	}
+
+	if err := b.rpcClient.CallContext(ctx, &traces, "debug_traceBlockByHash", result.Hash(), map[string]interface{}{"tracer": "callTracer"}); err != nil {
+		return nil, normalizeError(err, errors.ErrBlockNotFound)
+	}
+
+	if len(traces) != len(result.Transactions()) {
+		return nil, fmt.Errorf("debug_traceBlockByHash returned %d traces for %d transactions", len(traces), len(result.Transactions()))
+	}
+
+	transfers := make([]*internalTransfer, 0)
+	for i, trace := range traces {
+		if len(trace.Error) > 0 {
+			return nil, fmt.Errorf("failed to trace transaction %s: %s", result.Transactions()[i].Hash().Hex(), trace.Error)
+		}
+
+		transfers = append(transfers, callFrameTransfers(result.Transactions()[i].Hash(), trace.Result, nil)...)
+	}
+
+	return transfers, nil
+}
+
+// callFrameTransfers flatten the value transfers of the sub calls of frame, the top level
+// call is the transaction itself and reverted calls are skipped with their sub calls
+func callFrameTransfers(txHash common.Hash, frame *callFrame, traceAddress []int) []*internalTransfer {
+	if frame == nil || len(frame.Error) > 0 {
+		return nil
+	}
+
+	transfers := make([]*internalTransfer, 0)
+	if len(traceAddress) > 0 && frame.Value != nil && frame.Value.ToInt().Sign() > 0 && movesValue(frame.Type) {
+		transfers = append(transfers, &internalTransfer{
+			TxHash:       txHash,
+			From:         frame.From,
+			To:           frame.To,
+			Value:        frame.Value.ToInt(),
+			TraceAddress: traceAddress,
+		})
+	}
+
+	for i, call := range frame.Calls {
+		childAddress := append(append([]int{}, traceAddress...), i)
+		transfers = append(transfers, callFrameTransfers(txHash, call, childAddress)...)
+	}
+
+	return transfers
+}
+
+// movesValue tell whether a call type sends its value to another account,
+// DELEGATECALL and CALLCODE keep the value in the calling contract
+func movesValue(callType string) bool {
+	switch strings.ToUpper(callType) {
+	case "CALL", "CREATE", "CREATE2", "SELFDESTRUCT", "SUICIDE":
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Blockchain) traceBlockTransfers(ctx context.Context, result *types.Block) ([]*internalTransfer, error) {
+	var traces []*parityTrace
+	if err := b.rpcClient.CallContext(ctx, &traces, "trace_block", hexutil.EncodeBig(result.Number())); err != nil {
+		return nil, normalizeError(err, errors.ErrBlockNotFound)
+	}
+
+	for _, trace := range traces {
+		if trace.BlockHash != result.Hash() {
+			return nil, fmt.Errorf("%w: trace_block returned block %s instead of %s", errors.ErrBlockNotFound, trace.BlockHash.Hex(), result.Hash().Hex())
+		}
+	}
+
+	return parityTransfers(traces), nil
+}
+
+// parityTransfers pick the value transfers of the sub traces, a trace is reverted when
+// itself or one of its parents has an error
+func parityTransfers(traces []*parityTrace) []*internalTransfer {
+	reverted := make(map[string]bool)
+	for _, trace := range traces {
+		if trace.TransactionHash != nil && len(trace.Error) > 0 {
+			reverted[trace.TransactionHash.Hex()+"/"+formatTraceAddress(trace.TraceAddress)] = true
+		}
+	}
+
+	transfers := make([]*internalTransfer, 0)
+	for _, trace := range traces {
+		if trace.TransactionHash == nil || len(trace.TraceAddress) == 0 {
+			continue
+		}
+
+		revertedTrace := false
+		for i := 0; i <= len(trace.TraceAddress); i++ {
+			if reverted[trace.TransactionHash.Hex()+"/"+formatTraceAddress(trace.TraceAddress[:i])] {
+				revertedTrace = true
+				break
+			}
+		}
+		if revertedTrace {
+			continue
+		}
+
+		var transfer *internalTransfer
+		switch trace.Type {
+		case "call":
+			if movesValue(trace.Action.CallType) {
+				transfer = &internalTransfer{From: trace.Action.From, To: trace.Action.To, Value: trace.Action.Value.ToInt()}
+			}
+		case "create":
+			if trace.Result != nil {
+				transfer = &internalTransfer{From: trace.Action.From, To: trace.Result.Address, Value: trace.Action.Value.ToInt()}
+			}
+		case "suicide":
+			transfer = &internalTransfer{From: trace.Action.Address, To: trace.Action.RefundAddress, Value: trace.Action.Balance.ToInt()}
+		}
+
+		if transfer == nil || transfer.Value == nil || transfer.Value.Sign() <= 0 {
+			continue
+		}
+
+		transfer.TxHash = *trace.TransactionHash
+		transfer.TraceAddress = trace.TraceAddress
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers
+}
+
+func formatTraceAddress(traceAddress []int) string {
+	positions := make([]string, len(traceAddress))
+	for i, position := range traceAddress {
+		positions[i] = strconv.Itoa(position)
+	}
+
+	return strings.Join(positions, ".")
+}
@@ -0,0 +1,121 @@
+package evm
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCallFrameTransfers(t *testing.T) {
+	var frame *callFrame
+	if err := json.Unmarshal([]byte(`{
+		"type": "CALL", "from": "0x1000000000000000000000000000000000000001", "to": "0x2000000000000000000000000000000000000002", "value": "0x5",
+		"calls": [
+			{"type": "CALL", "from": "0x2000000000000000000000000000000000000002", "to": "0x3000000000000000000000000000000000000003", "value": "0x2"},
+			{"type": "DELEGATECALL", "from": "0x2000000000000000000000000000000000000002", "to": "0x4000000000000000000000000000000000000004", "value": "0x3"},
+			{"type": "CALL", "from": "0x2000000000000000000000000000000000000002", "to": "0x5000000000000000000000000000000000000005", "value": "0x1", "error": "execution reverted",
+				"calls": [{"type": "CALL", "from": "0x5000000000000000000000000000000000000005", "to": "0x6000000000000000000000000000000000000006", "value": "0x1"}]},
+			{"type": "STATICCALL", "from": "0x2000000000000000000000000000000000000002", "to": "0x7000000000000000000000000000000000000007",
+				"calls": [{"type": "CALL", "from": "0x7000000000000000000000000000000000000007", "to": "0x8000000000000000000000000000000000000008", "value": "0x4"}]}
+		]
+	}`), &frame); err != nil {
+		t.Fatal(err)
+	}
+
+	transfers := callFrameTransfers(common.HexToHash("0x01"), frame, nil)
+	if len(transfers) != 2 {
+		t.Fatalf("expected 2 transfers, got %d", len(transfers))
+	}
+
+	if transfers[0].To != common.HexToAddress("0x3000000000000000000000000000000000000003") || transfers[0].Value.Int64() != 2 || formatTraceAddress(transfers[0].TraceAddress) != "0" {
+		t.Errorf("unexpected first transfer %+v", transfers[0])
+	}
+
+	if transfers[1].To != common.HexToAddress("0x8000000000000000000000000000000000000008") || formatTraceAddress(transfers[1].TraceAddress) != "3.0" {
+		t.Errorf("unexpected second transfer %+v", transfers[1])
+	}
+}
+
+func TestParityTransfers(t *testing.T) {
+	var traces []*parityTrace
+	if err := json.Unmarshal([]byte(`[
+		{"type": "call", "action": {"callType": "call", "from": "0x1000000000000000000000000000000000000001", "to": "0x2000000000000000000000000000000000000002", "value": "0x5"}, "transactionHash": "0x0100000000000000000000000000000000000000000000000000000000000001", "traceAddress": []},
+		{"type": "call", "action": {"callType": "call", "from": "0x2000000000000000000000000000000000000002", "to": "0x3000000000000000000000000000000000000003", "value": "0x2"}, "transactionHash": "0x0100000000000000000000000000000000000000000000000000000000000001", "traceAddress": [0]},
+		{"type": "call", "action": {"callType": "call", "from": "0x2000000000000000000000000000000000000002", "to": "0x5000000000000000000000000000000000000005", "value": "0x1"}, "transactionHash": "0x0100000000000000000000000000000000000000000000000000000000000001", "traceAddress": [1], "error": "Reverted"},
+		{"type": "call", "action": {"callType": "call", "from": "0x5000000000000000000000000000000000000005", "to": "0x6000000000000000000000000000000000000006", "value": "0x1"}, "transactionHash": "0x0100000000000000000000000000000000000000000000000000000000000001", "traceAddress": [1, 0]},
+		{"type": "suicide", "action": {"address": "0x2000000000000000000000000000000000000002", "refundAddress": "0x9000000000000000000000000000000000000009", "balance": "0x3"}, "transactionHash": "0x0100000000000000000000000000000000000000000000000000000000000001", "traceAddress": [2]},
+		{"type": "reward", "action": {"value": "0x10"}, "traceAddress": []}
+	]`), &traces); err != nil {
+		t.Fatal(err)
+	}
+
+	transfers := parityTransfers(traces)
+	if len(transfers) != 2 {
+		t.Fatalf("expected 2 transfers, got %d", len(transfers))
+	}
+
+	if transfers[0].To != common.HexToAddress("0x3000000000000000000000000000000000000003") {
+		t.Errorf("unexpected first transfer %+v", transfers[0])
+	}
+
+	if transfers[1].From != common.HexToAddress("0x2000000000000000000000000000000000000002") || transfers[1].To != common.HexToAddress("0x9000000000000000000000000000000000000009") || transfers[1].Value.Int64() != 3 {
+		t.Errorf("unexpected second transfer %+v", transfers[1])
+	}
+}
+
+func TestBlockchain_BuildInternalTransactions(t *testing.T) {
+	result := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100)})
+	txHash := common.HexToHash("0x0100000000000000000000000000000000000000000000000000000000000001")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+
+		if msg.Method != "trace_block" {
+			t.Errorf("unexpected method %s", msg.Method)
+		}
+
+		traces := make([]map[string]interface{}, 0)
+		for i, to := range []string{"0x3000000000000000000000000000000000000003", "0x4000000000000000000000000000000000000004"} {
+			traces = append(traces, map[string]interface{}{
+				"type":            "call",
+				"action":          map[string]interface{}{"callType": "call", "from": "0x2000000000000000000000000000000000000002", "to": to, "value": "0x1"},
+				"blockHash":       result.Hash(),
+				"transactionHash": txHash,
+				"traceAddress":    []int{i},
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": traces})
+	}))
+	defer server.Close()
+
+	b := newPendingBlockchain(t, server.URL)
+	defer b.Close()
+	b.traceMode = TraceModeTraceBlock
+
+	transactions, err := b.buildInternalTransactions(context.Background(), result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(transactions))
+	}
+
+	// the top-level transfer is TxOut 0 and token transfers use their log index
+	for i, trans := range transactions {
+		if trans.TxOut != InternalTxOutOffset+uint(i) {
+			t.Fatalf("expected internal transfer %d to have TxOut %d, got %d", i, InternalTxOutOffset+uint(i), trans.TxOut)
+		}
+	}
+}
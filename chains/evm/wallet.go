@@ -4,9 +4,13 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"encoding/json"
+	"fmt"
 	"math"
 	"math/big"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -20,6 +24,7 @@ import (
 	"github.com/volatiletech/null/v9"
 	"github.com/zsmartex/mergo"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
 	"github.com/zsmartex/multichain/pkg/wallet"
 )
@@ -28,48 +33,222 @@ type Options struct {
 	Erc20ContractAddress string              `json:"erc20_contract_address"`
 	GasPrice             *big.Int            `json:"gas_price"`
 	GasLimit             uint64              `json:"gas_limit"`
+	GasMultiplier        float64             `json:"gas_multiplier"`
+	MaxGasLimit          uint64              `json:"max_gas_limit"`
 	GasRate              wallet.GasPriceRate `json:"gas_rate"`
 	SubtractFee          bool                `json:"subtract_fee"`
+
+	// TxType select the transaction type, auto use EIP-1559 when the chain has a base fee
+	TxType               TxType   `json:"tx_type"`
+	MaxFeePerGas         *big.Int `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas *big.Int `json:"max_priority_fee_per_gas"`
+
+	// ForwarderFactory deploys the CREATE2 forwarders used as deposit addresses, see Wallet.ForwarderAddress
+	ForwarderFactory      string `json:"forwarder_factory"`
+	ForwarderInitCodeHash string `json:"forwarder_init_code_hash"`
 }
 
+// gas_limit is estimated with eth_estimateGas unless set in the currency or transaction options
 var defaultEvmFee = map[string]interface{}{
-	"gas_limit": 21_000,
-	"gas_rate":  wallet.GasPriceRateStandard,
+	"gas_multiplier": 1.2,
+	"gas_rate":       wallet.GasPriceRateStandard,
 }
 
 var defaultErc20Fee = map[string]interface{}{
-	"gas_limit": 90_000,
-	"gas_rate":  wallet.GasPriceRateStandard,
+	"gas_multiplier": 1.2,
+	"gas_rate":       wallet.GasPriceRateStandard,
 }
 
 type Wallet struct {
 	client   *ethclient.Client
 	currency *currency.Currency    // selected currency for this wallet
 	wallet   *wallet.SettingWallet // selected wallet for this currency
+	native   *currency.Currency    // last configured currency without a contract, it pays the fees
+
+	nonces *NonceManager
+	abis   *ABIRegistry
+
+	hd        *wallet.HDSetting
+	nextIndex uint32 // next index CreateAddress derives, see wallet.HDSetting
+
+	mu     sync.Mutex
+	london *bool // whether the node chain has activated EIP-1559, nil until checked
+}
+
+func init() {
+	wallet.Register("evm", NewWallet)
 }
 
 func NewWallet() wallet.Wallet {
-	return &Wallet{}
+	return &Wallet{
+		nonces: DefaultNonceManager,
+		abis:   NewABIRegistry(),
+	}
 }
 
-func (w *Wallet) Configure(settings *wallet.Setting) {
+// SetNonceManager replace the DefaultNonceManager, wallets sending from the same
+// address must share their nonce manager
+func (w *Wallet) SetNonceManager(nonces *NonceManager) {
+	w.nonces = nonces
+}
+
+// SetABIRegistry replace the registry used to encode the token calls
+func (w *Wallet) SetABIRegistry(abis *ABIRegistry) {
+	w.abis = abis
+}
+
+func (w *Wallet) Configure(settings *wallet.Setting) error {
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("failed to configure evm wallet: %w", err)
+	}
+
 	if settings.Wallet != nil {
-		w.wallet = settings.Wallet
+		if err := validateURI(settings.Wallet.URI); err != nil {
+			return fmt.Errorf("failed to configure evm wallet: %w", err)
+		}
+	}
+
+	var currencyABI *abi.ABI
+	if settings.Currency != nil {
+		for _, standard := range tokenStandards {
+			if address, ok := settings.Currency.Options[standard.OptionKey()]; ok {
+				if s, ok := address.(string); !ok || !common.IsHexAddress(s) {
+					return fmt.Errorf("failed to configure evm wallet: %w: currency %s %s is invalid", errors.ErrInvalidConfiguration, settings.Currency.ID, standard.OptionKey())
+				}
+			}
+		}
+
+		parsed, err := w.abis.parseCurrency(settings.Currency)
+		if err != nil {
+			return fmt.Errorf("failed to configure evm wallet: %w", err)
+		}
+		currencyABI = parsed
+
+		if err := validateForwarder(settings.Currency); err != nil {
+			return fmt.Errorf("failed to configure evm wallet: %w", err)
+		}
+
+		if id, ok := settings.Currency.Options["native_currency"]; ok {
+			if _, ok := id.(string); !ok {
+				return fmt.Errorf("failed to configure evm wallet: %w: currency %s native_currency must be a string", errors.ErrInvalidConfiguration, settings.Currency.ID)
+			}
+		}
+	}
+
+	if settings.HD != nil {
+		w.hd = settings.HD
+		atomic.StoreUint32(&w.nextIndex, settings.HD.NextIndex)
+	}
+
+	if settings.Wallet != nil {
+		if err := w.validateSecret(settings.Wallet); err != nil {
+			return fmt.Errorf("failed to configure evm wallet: %w", errors.Wrap(errors.ErrInvalidConfiguration, err))
+		}
 
 		rpcClient, err := rpc.Dial(settings.Wallet.URI)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("failed to configure evm wallet: %w", errors.Wrap(errors.ErrInvalidConfiguration, err))
 		}
 
+		if w.client != nil {
+			w.client.Close()
+		}
+
+		w.wallet = settings.Wallet
 		w.client = ethclient.NewClient(rpcClient)
+
+		w.mu.Lock()
+		w.london = nil
+		w.mu.Unlock()
 	}
 
 	if settings.Currency != nil {
+		w.abis.setCurrency(settings.Currency, currencyABI)
 		w.currency = settings.Currency
+
+		if contract, _ := contractOf(settings.Currency); len(contract) == 0 {
+			w.native = settings.Currency
+		}
 	}
+
+	return nil
 }
 
-func (w *Wallet) CreateAddress(_ context.Context) (address, secret string, err error) {
+func (w *Wallet) Health(ctx context.Context) error {
+	if w.client == nil {
+		return fmt.Errorf("%w: evm wallet is not configured", errors.ErrInvalidConfiguration)
+	}
+
+	return health(ctx, w.client)
+}
+
+func (w *Wallet) Close() error {
+	if w.client != nil {
+		w.client.Close()
+	}
+
+	w.client = ethclient.NewClient(closedClient())
+
+	return nil
+}
+
+// validateSecret check that secret is a private key of the wallet address
+func (w *Wallet) validateSecret(settingWallet *wallet.SettingWallet) error {
+	if len(settingWallet.Address) > 0 && !common.IsHexAddress(settingWallet.Address) {
+		return fmt.Errorf("wallet address %s is invalid", settingWallet.Address)
+	}
+
+	if len(settingWallet.Secret) == 0 {
+		return nil
+	}
+
+	privateKey, err := w.privateKey(settingWallet.Secret)
+	if err != nil {
+		return errors.New("wallet secret is not a valid private key")
+	}
+
+	if len(settingWallet.Address) > 0 && crypto.PubkeyToAddress(privateKey.PublicKey) != common.HexToAddress(settingWallet.Address) {
+		return fmt.Errorf("wallet secret does not belong to address %s", settingWallet.Address)
+	}
+
+	return nil
+}
+
+// privateKey return the key of secret, which is the derivation index of the key when the wallet is HD
+func (w *Wallet) privateKey(secret string) (*ecdsa.PrivateKey, error) {
+	if index, ok := wallet.ParseIndex(secret); ok && w.hd != nil {
+		return w.hd.DeriveKey(w.hd.DerivationPath(44, 60), index)
+	}
+
+	return crypto.HexToECDSA(strings.TrimPrefix(secret, "0x"))
+}
+
+// DeriveAddress return the address at index of the HD wallet
+func (w *Wallet) DeriveAddress(_ context.Context, index uint32) (string, error) {
+	if w.hd == nil {
+		return "", fmt.Errorf("%w: evm wallet is not hd", errors.ErrInvalidConfiguration)
+	}
+
+	privateKey, err := w.hd.DeriveKey(w.hd.DerivationPath(44, 60), index)
+	if err != nil {
+		return "", err
+	}
+
+	return crypto.PubkeyToAddress(privateKey.PublicKey).Hex(), nil
+}
+
+func (w *Wallet) CreateAddress(ctx context.Context) (address, secret string, err error) {
+	if w.hd != nil {
+		index := atomic.AddUint32(&w.nextIndex, 1) - 1
+
+		address, err := w.DeriveAddress(ctx, index)
+		if err != nil {
+			return "", "", err
+		}
+
+		return address, strconv.FormatUint(uint64(index), 10), nil
+	}
+
 	privateKey, err := crypto.GenerateKey()
 	if err != nil {
 		return "", "", err
@@ -92,29 +271,56 @@ func (w *Wallet) CreateAddress(_ context.Context) (address, secret string, err e
 func (w *Wallet) PrepareDepositCollection(ctx context.Context, tx *transaction.Transaction, depositSpreads []*transaction.Transaction, depositCurrency *currency.Currency) (*transaction.Transaction, error) {
 	options := w.mergeOptions(defaultErc20Fee, depositCurrency.Options)
 
-	if len(options.Erc20ContractAddress) == 0 {
-		return nil, nil
+	// forwarder deposits are flushed by Sweep which the wallet pays for
+	if _, _, ok := forwarderOf(options); ok || len(options.Erc20ContractAddress) == 0 {
+		return nil, fmt.Errorf("%w: deposit of %s needs no collection fee", errors.ErrNotSupported, depositCurrency.ID)
+	}
+
+	fee, err := w.suggestFee(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.abis.RegisterCurrency(depositCurrency); err != nil {
+		return nil, err
 	}
 
-	if options.GasPrice.Cmp(big.NewInt(0)) == 0 {
-		gasPrice, err := w.calculateGasPrice(ctx, options)
+	abiJSON := w.abis.ABI(depositCurrency)
+
+	// each spread is a token transfer sent by the deposit address, tx tops it up with their gas
+	depositAddress := common.HexToAddress(w.normalizeAddress(tx.ToAddress))
+	contractAddress := common.HexToAddress(w.normalizeAddress(options.Erc20ContractAddress))
+
+	var gasLimit uint64
+	for _, spread := range depositSpreads {
+		value := spread.Amount.Shift(int32(depositCurrency.Subunits)).BigInt()
+
+		data, err := abiJSON.Pack("transfer", common.HexToAddress(w.normalizeAddress(spread.ToAddress)), value)
 		if err != nil {
 			return nil, err
 		}
 
-		options.GasPrice = gasPrice
-	}
+		spreadGasLimit, err := w.gasLimit(ctx, options, ethereum.CallMsg{
+			From: depositAddress,
+			To:   &contractAddress,
+			Data: data,
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	fees := decimal.NewFromBigInt(new(big.Int).Mul(options.GasPrice, big.NewInt(int64(options.GasLimit))), -w.currency.Subunits)
-	amount := fees.Mul(decimal.NewFromInt(int64(len(depositSpreads))))
+		gasLimit += spreadGasLimit
+	}
 
-	tx.Amount = amount
+	tx.Amount = decimal.NewFromBigInt(fee.Cost(gasLimit), -w.currency.Subunits)
 
 	return w.createEvmTransaction(ctx, tx, nil)
 }
 
 func (w *Wallet) CreateTransaction(ctx context.Context, tx *transaction.Transaction, options map[string]interface{}) (*transaction.Transaction, error) {
-	if len(w.ContractAddress()) > 0 {
+	if _, standard := contractOf(w.currency); isNFT(standard) {
+		return w.createNFTTransaction(ctx, tx, options)
+	} else if len(w.ContractAddress()) > 0 {
 		return w.createErc20Transaction(ctx, tx, options)
 	} else {
 		return w.createEvmTransaction(ctx, tx, options)
@@ -124,57 +330,35 @@ func (w *Wallet) CreateTransaction(ctx context.Context, tx *transaction.Transact
 func (w *Wallet) createEvmTransaction(ctx context.Context, tx *transaction.Transaction, opt map[string]interface{}) (t *transaction.Transaction, err error) {
 	options := w.mergeOptions(defaultEvmFee, w.currency.Options, tx.Options, opt)
 
-	if options.GasPrice.Cmp(big.NewInt(0)) == 0 {
-		gasPrice, err := w.calculateGasPrice(ctx, options)
-		if err != nil {
-			return nil, err
-		}
-
-		options.GasPrice = gasPrice
+	gasFee, err := w.suggestFee(ctx, options)
+	if err != nil {
+		return nil, err
 	}
 
 	amount := w.ConvertToBaseUnit(tx.Amount)
-	fee := decimal.NewFromBigInt(new(big.Int).Mul(options.GasPrice, big.NewInt(int64(options.GasLimit))), 0)
-
-	if options.SubtractFee {
-		amount = amount.Sub(fee)
-	}
-
-	fromAddress := common.HexToAddress(w.normalizeAddress(w.wallet.Address))
 	toAddress := common.HexToAddress(w.normalizeAddress(tx.ToAddress))
 
-	nonce, err := w.client.PendingNonceAt(context.Background(), fromAddress)
+	gasLimit, err := w.gasLimit(ctx, options, ethereum.CallMsg{
+		From:  common.HexToAddress(w.normalizeAddress(w.wallet.Address)),
+		To:    &toAddress,
+		Value: amount.BigInt(),
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	chainID, err := w.client.NetworkID(context.Background())
-	if err != nil {
-		return nil, err
-	}
+	fee := decimal.NewFromBigInt(gasFee.Cost(gasLimit), 0)
 
-	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(w.wallet.Secret, "0x"))
-	if err != nil {
-		return nil, err
+	if options.SubtractFee {
+		amount = amount.Sub(fee)
 	}
 
-	signedTx, err := types.SignTx(
-		types.NewTx(&types.LegacyTx{
-			Gas:      options.GasLimit,
-			GasPrice: options.GasPrice,
-			Nonce:    nonce,
-			Value:    amount.BigInt(),
-			To:       &toAddress,
-		}),
-		types.NewEIP155Signer(chainID),
-		privateKey,
-	)
-
-	if err := w.client.SendTransaction(context.Background(), signedTx); err != nil {
+	signedTx, err := w.sendTransaction(ctx, toAddress, amount.BigInt(), nil, gasLimit, gasFee)
+	if err != nil {
 		return nil, err
 	}
 
-	tx.Fee = decimal.NewNullDecimal(w.ConvertFromBaseUnit(fee))
+	w.setFee(tx, gasFee.Cost(gasLimit))
 	tx.Status = transaction.StatusPending
 	tx.TxHash = null.StringFrom(signedTx.Hash().Hex())
 
@@ -182,69 +366,122 @@ func (w *Wallet) createEvmTransaction(ctx context.Context, tx *transaction.Trans
 }
 
 func (w *Wallet) createErc20Transaction(ctx context.Context, tx *transaction.Transaction, opt map[string]interface{}) (*transaction.Transaction, error) {
-	options := w.mergeOptions(defaultEvmFee, w.currency.Options, tx.Options, opt)
-
-	if options.GasPrice.Cmp(big.NewInt(0)) == 0 {
-		gasPrice, err := w.calculateGasPrice(ctx, options)
-		if err != nil {
-			return nil, err
-		}
+	options := w.mergeOptions(defaultErc20Fee, w.currency.Options, tx.Options, opt)
 
-		options.GasPrice = gasPrice
+	gasFee, err := w.suggestFee(ctx, options)
+	if err != nil {
+		return nil, err
 	}
 
-	fee := decimal.NewFromBigInt(new(big.Int).Mul(options.GasPrice, big.NewInt(int64(options.GasLimit))), 0)
-	fromAddress := common.HexToAddress(w.normalizeAddress(w.wallet.Address))
 	toAddress := common.HexToAddress(w.normalizeAddress(tx.ToAddress))
 	contractAddress := common.HexToAddress(w.normalizeAddress(w.ContractAddress()))
 	amount := w.ConvertToBaseUnit(tx.Amount)
 
-	abiJSON, err := abi.JSON(strings.NewReader(abiDefinition))
+	data, err := w.abis.ABI(w.currency).Pack("transfer", toAddress, amount.BigInt())
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := abiJSON.Pack("transfer", toAddress, amount.BigInt())
+	gasLimit, err := w.gasLimit(ctx, options, ethereum.CallMsg{
+		From: common.HexToAddress(w.normalizeAddress(w.wallet.Address)),
+		To:   &contractAddress,
+		Data: data,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	nonce, err := w.client.PendingNonceAt(context.Background(), fromAddress)
+	signedTx, err := w.sendTransaction(ctx, contractAddress, nil, data, gasLimit, gasFee)
 	if err != nil {
 		return nil, err
 	}
 
-	chainID, err := w.client.NetworkID(context.Background())
+	w.setFee(tx, gasFee.Cost(gasLimit))
+	tx.Status = transaction.StatusPending
+	tx.TxHash = null.StringFrom(signedTx.Hash().Hex())
+
+	return tx, nil
+}
+
+// sendTransaction sign a transaction from the wallet address and broadcast it,
+// an EIP-1559 transaction is built when fee is dynamic
+func (w *Wallet) sendTransaction(ctx context.Context, to common.Address, value *big.Int, data []byte, gasLimit uint64, fee *gasFee) (*types.Transaction, error) {
+	fromAddress := common.HexToAddress(w.normalizeAddress(w.wallet.Address))
+
+	privateKey, err := w.privateKey(w.wallet.Secret)
 	if err != nil {
 		return nil, err
 	}
 
-	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(w.wallet.Secret, "0x"))
+	chainID, err := w.client.ChainID(ctx)
+	if err != nil {
+		return nil, normalizeError(err, nil)
+	}
+
+	nonce, err := w.nonces.Acquire(ctx, w.client, chainID, fromAddress)
 	if err != nil {
 		return nil, err
 	}
 
-	signedTx, err := types.SignTx(
-		types.NewTx(&types.LegacyTx{
-			Gas:      options.GasLimit,
-			GasPrice: options.GasPrice,
-			Nonce:    nonce,
-			To:       &contractAddress,
-			Data:     data,
-		}),
-		types.NewEIP155Signer(chainID),
-		privateKey,
-	)
+	signedTx, err := types.SignNewTx(privateKey, types.LatestSignerForChainID(chainID), newTxData(chainID, nonce, to, value, data, gasLimit, fee))
+	if err != nil {
+		return nil, w.releaseNonce(ctx, chainID, fromAddress, nonce, err)
+	}
 
 	if err := w.client.SendTransaction(ctx, signedTx); err != nil {
+		err = normalizeError(err, nil)
+
+		switch {
+		case errors.Is(err, errors.ErrAlreadyKnown):
+			// the very same transaction is already in the node pool
+			return signedTx, nil
+		case errors.Is(err, errors.ErrNonceTooLow), errors.Is(err, errors.ErrReplacementUnderpriced), errors.Is(err, errors.ErrNodeUnavailable):
+			// the nonce is used by another transaction or this one may have been broadcast
+			w.nonces.Reset(chainID, fromAddress)
+		default:
+			err = w.releaseNonce(ctx, chainID, fromAddress, nonce, err)
+		}
+
 		return nil, err
 	}
 
-	tx.Fee = decimal.NewNullDecimal(w.ConvertFromBaseUnit(fee))
-	tx.Status = transaction.StatusPending
-	tx.TxHash = null.StringFrom(signedTx.Hash().Hex())
+	return signedTx, nil
+}
 
-	return tx, nil
+// releaseNonce give back the nonce of a transaction which failed with err, when it can't be
+// released the next send syncs with the node which never saw it
+func (w *Wallet) releaseNonce(ctx context.Context, chainID *big.Int, address common.Address, nonce uint64, err error) error {
+	if releaseErr := w.nonces.Release(ctx, chainID, address, nonce); releaseErr != nil {
+		w.nonces.Reset(chainID, address)
+
+		return fmt.Errorf("%w (nonce %d was not released: %v)", err, nonce, releaseErr)
+	}
+
+	return err
+}
+
+func newTxData(chainID *big.Int, nonce uint64, to common.Address, value *big.Int, data []byte, gasLimit uint64, fee *gasFee) types.TxData {
+	if fee.Dynamic {
+		return &types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			GasTipCap: fee.GasTipCap,
+			GasFeeCap: fee.GasFeeCap,
+			Gas:       gasLimit,
+			To:        &to,
+			Value:     value,
+			Data:      data,
+		}
+	}
+
+	return &types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: fee.GasPrice,
+		Gas:      gasLimit,
+		To:       &to,
+		Value:    value,
+		Data:     data,
+	}
 }
 
 func (w *Wallet) normalizeAddress(address string) string {
@@ -266,7 +503,7 @@ func (w *Wallet) ContractAddress() string {
 func (w *Wallet) calculateGasPrice(ctx context.Context, options Options) (*big.Int, error) {
 	gasPrice, err := w.client.SuggestGasPrice(ctx)
 	if err != nil {
-		return nil, err
+		return nil, normalizeError(err, nil)
 	}
 
 	var rate float64
@@ -283,9 +520,15 @@ func (w *Wallet) calculateGasPrice(ctx context.Context, options Options) (*big.I
 }
 
 func (w *Wallet) LoadBalance(ctx context.Context) (balance decimal.Decimal, err error) {
-	if len(w.ContractAddress()) > 0 {
-		return w.loadBalanceErc20Balance(ctx, w.wallet.Address)
-	} else {
+	contractAddress, standard := contractOf(w.currency)
+
+	switch standard {
+	case TokenStandardERC1155:
+		return decimal.Zero, fmt.Errorf("balance of erc1155 currency %s needs a token id", w.currency.ID)
+	case TokenStandardERC20, TokenStandardERC721:
+		// erc721 balanceOf is the number of tokens held
+		return w.loadBalanceErc20Balance(ctx, contractAddress, w.wallet.Address)
+	default:
 		return w.loadBalanceEvmBalance(ctx, w.wallet.Address)
 	}
 }
@@ -293,30 +536,25 @@ func (w *Wallet) LoadBalance(ctx context.Context) (balance decimal.Decimal, err
 func (w *Wallet) loadBalanceEvmBalance(ctx context.Context, address string) (balance decimal.Decimal, err error) {
 	result, err := w.client.BalanceAt(ctx, common.HexToAddress(address), nil)
 	if err != nil {
-		return decimal.Zero, err
+		return decimal.Zero, normalizeError(err, nil)
 	}
 
 	return decimal.NewFromBigInt(result, -w.currency.Subunits), nil
 }
 
-func (w *Wallet) loadBalanceErc20Balance(ctx context.Context, address string) (balance decimal.Decimal, err error) {
-	abiJSON, err := abi.JSON(strings.NewReader(abiDefinition))
-	if err != nil {
-		return decimal.Zero, err
-	}
-
-	data, err := abiJSON.Pack("balanceOf", common.HexToAddress(address))
+func (w *Wallet) loadBalanceErc20Balance(ctx context.Context, contract, address string) (balance decimal.Decimal, err error) {
+	data, err := w.abis.ABI(w.currency).Pack("balanceOf", common.HexToAddress(address))
 	if err != nil {
 		return decimal.Zero, err
 	}
 
-	contractAddress := common.HexToAddress(w.normalizeAddress(w.ContractAddress()))
+	contractAddress := common.HexToAddress(w.normalizeAddress(contract))
 	result, err := w.client.CallContract(ctx, ethereum.CallMsg{
 		To:   &contractAddress,
 		Data: data,
 	}, nil)
 	if err != nil {
-		return decimal.Zero, err
+		return decimal.Zero, normalizeError(err, nil)
 	}
 
 	hex := hexutil.Encode(result)
@@ -335,12 +573,12 @@ func (w *Wallet) loadBalanceErc20Balance(ctx context.Context, address string) (b
 }
 
 func (w *Wallet) mergeOptions(first map[string]interface{}, steps ...map[string]interface{}) Options {
-	if first == nil {
-		first = make(map[string]interface{})
+	// copy first so the merge never writes into the default fee maps
+	opts := make(map[string]interface{}, len(first))
+	for k, v := range first {
+		opts[k] = v
 	}
 
-	opts := first
-
 	for _, step := range steps {
 		mergo.Merge(&opts, step, mergo.WithOverride)
 	}
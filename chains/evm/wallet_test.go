@@ -7,33 +7,38 @@ import (
 	"github.com/shopspring/decimal"
 
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
 	"github.com/zsmartex/multichain/pkg/wallet"
 )
 
-func newWallet() wallet.Wallet {
+func newWallet(t *testing.T) wallet.Wallet {
 	w := NewWallet()
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Wallet: &wallet.SettingWallet{
 			URI:     "http://demo.zsmartex.com:8575",
 			Address: "0xD2E03d98cd8af2D84522Cf11D471AaA4ca60D8CA",
 			Secret:  "0x5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515",
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	return w
 }
 
 func TestWallet_CreateAddress(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "ETH",
 			Subunits: 18,
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	address, secret, err := w.CreateAddress(context.Background())
 	if err != nil {
@@ -44,14 +49,16 @@ func TestWallet_CreateAddress(t *testing.T) {
 }
 
 func TestWallet_LoadEVMBalance(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "ETH",
 			Subunits: 18,
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	balance, err := w.LoadBalance(context.Background())
 	if err != nil {
@@ -62,9 +69,9 @@ func TestWallet_LoadEVMBalance(t *testing.T) {
 }
 
 func TestWallet_LoadErc20Balance(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "USDT",
 			Subunits: 18,
@@ -72,7 +79,9 @@ func TestWallet_LoadErc20Balance(t *testing.T) {
 				"erc20_contract_address": "0x337610d27c682e347c9cd60bd4b3b107c9d34ddd",
 			},
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	balance, err := w.LoadBalance(context.Background())
 	if err != nil {
@@ -83,14 +92,16 @@ func TestWallet_LoadErc20Balance(t *testing.T) {
 }
 
 func TestWallet_CreateEVMTransaction(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "ETH",
 			Subunits: 18,
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	tx, err := w.CreateTransaction(context.Background(), &transaction.Transaction{
 		ToAddress: "0xbecFbfd1BaDdB4FE857CCB428555b9EA47fFCC40",
@@ -106,9 +117,9 @@ func TestWallet_CreateEVMTransaction(t *testing.T) {
 }
 
 func TestWallet_CreateErc20Transaction(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "USDT",
 			Subunits: 18,
@@ -116,7 +127,9 @@ func TestWallet_CreateErc20Transaction(t *testing.T) {
 				"erc20_contract_address": "0x337610d27c682e347c9cd60bd4b3b107c9d34ddd",
 			},
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	tx, err := w.CreateTransaction(context.Background(), &transaction.Transaction{
 		ToAddress: "0xF37111De2f6AE2f64Be1e59472b5C50801540C8c",
@@ -130,14 +143,16 @@ func TestWallet_CreateErc20Transaction(t *testing.T) {
 }
 
 func TestWallet_PrepareDepositCollection(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "ETH",
 			Subunits: 18,
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	depositSpreadCollectionTx, err := w.PrepareDepositCollection(
 		context.Background(),
@@ -168,3 +183,77 @@ func TestWallet_PrepareDepositCollection(t *testing.T) {
 
 	t.Log(depositSpreadCollectionTx)
 }
+
+func TestWallet_CreateHDAddress(t *testing.T) {
+	w := NewWallet().(*Wallet)
+
+	if err := w.Configure(&wallet.Setting{
+		HD: &wallet.HDSetting{
+			Master:    "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4",
+			NextIndex: 1,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	address, secret, err := w.CreateAddress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if address != "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0" || secret != "1" {
+		t.Fatalf("unexpected address %s with secret %s", address, secret)
+	}
+
+	// the derivation index is accepted as the secret of the wallet address
+	if err := w.Configure(&wallet.Setting{
+		Wallet: &wallet.SettingWallet{
+			URI:     "http://127.0.0.1:8545",
+			Address: "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+			Secret:  "0",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+}
+
+func TestWallet_Close(t *testing.T) {
+	w := NewWallet()
+	if err := w.Configure(&wallet.Setting{
+		Wallet: &wallet.SettingWallet{
+			URI:     "http://127.0.0.1:8545",
+			Address: "0xD2E03d98cd8af2D84522Cf11D471AaA4ca60D8CA",
+			Secret:  "0x5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515",
+		},
+		Currency: &currency.Currency{ID: "eth", Subunits: 18},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Health(context.Background()); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+
+	if _, err := w.LoadBalance(context.Background()); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+}
+
+func TestWallet_PrepareNativeDepositCollection(t *testing.T) {
+	w := NewWallet()
+	eth := &currency.Currency{ID: "eth", Subunits: 18}
+	if err := w.Configure(&wallet.Setting{Currency: eth}); err != nil {
+		t.Fatal(err)
+	}
+
+	// native deposits pay their own collection fee
+	collection, err := w.PrepareDepositCollection(context.Background(), &transaction.Transaction{ToAddress: "0xD2E03d98cd8af2D84522Cf11D471AaA4ca60D8CA"}, nil, eth)
+	if !errors.Is(err, errors.ErrNotSupported) || collection != nil {
+		t.Fatalf("expected no deposit collection, got %v, %v", collection, err)
+	}
+}
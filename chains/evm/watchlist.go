@@ -0,0 +1,85 @@
+package evm
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zsmartex/multichain/pkg/currency"
+)
+
+var (
+	transferMethodID     = common.FromHex("0xa9059cbb")
+	transferFromMethodID = common.FromHex("0x23b872dd")
+)
+
+// mayConcernWatchlist tell without fetching the receipt whether tx could carry a transfer from or to
+// a watched address, it only returns false when the receipt cannot change the answer
+func (b *Blockchain) mayConcernWatchlist(tx *types.Transaction, logsBloom types.Bloom) bool {
+	if tx.To() == nil {
+		return b.contractsMayLog(logsBloom)
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return true
+	}
+
+	if b.watchlist.Contains(from.Hex()) || b.watchlist.Contains(tx.To().Hex()) {
+		return true
+	}
+
+	if len(tx.Data()) == 0 {
+		return false
+	}
+
+	if b.findContract(*tx.To()) != nil {
+		if parties, ok := tokenTransferParties(tx.Data()); ok {
+			for _, party := range parties {
+				if b.watchlist.Contains(party.Hex()) {
+					return true
+				}
+			}
+
+			return false
+		}
+	}
+
+	// calls to other contracts (routers, batchers, multisigs) may still move our tokens
+	return b.contractsMayLog(logsBloom)
+}
+
+// contractsMayLog check the block logs bloom for any of the configured contracts
+func (b *Blockchain) contractsMayLog(logsBloom types.Bloom) bool {
+	for _, c := range b.contracts {
+		if address, _ := contractOf(c); types.BloomLookup(logsBloom, common.HexToAddress(address)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Blockchain) findContract(contractAddress common.Address) *currency.Currency {
+	for _, c := range b.contracts {
+		if address, _ := contractOf(c); strings.EqualFold(address, contractAddress.Hex()) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// tokenTransferParties decode the sender and recipient addresses of a transfer or transferFrom call,
+// the sender of a transfer call is the transaction sender and is not returned
+func tokenTransferParties(data []byte) ([]common.Address, bool) {
+	switch {
+	case len(data) == 4+32*2 && bytes.Equal(data[:4], transferMethodID):
+		return []common.Address{common.BytesToAddress(data[4:36])}, true
+	case len(data) == 4+32*3 && bytes.Equal(data[:4], transferFromMethodID):
+		return []common.Address{common.BytesToAddress(data[4:36]), common.BytesToAddress(data[36:68])}, true
+	default:
+		return nil, false
+	}
+}
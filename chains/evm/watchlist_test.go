@@ -0,0 +1,74 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/watchlist"
+)
+
+func TestMayConcernWatchlist(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	deposit := common.HexToAddress("0xf37111de2f6ae2f64be1e59472b5c50801540c8c")
+	other := common.HexToAddress("0xbecfbfd1baddb4fe857ccb428555b9ea47ffcc40")
+	token := common.HexToAddress("0xe9e7cea3dedca5984780bafc599bd69add087d56")
+	router := common.HexToAddress("0x10ed43c718714eb63d5aa57b78b54704e256024e")
+
+	b := &Blockchain{
+		contracts: []*currency.Currency{
+			{ID: "busd", Subunits: 18, Options: map[string]interface{}{"erc20_contract_address": token.Hex()}},
+		},
+		watchlist: watchlist.NewSet(deposit.Hex()),
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(56))
+	sign := func(to common.Address, data []byte) *types.Transaction {
+		tx, err := types.SignNewTx(key, signer, &types.LegacyTx{To: &to, Gas: 21000, GasPrice: big.NewInt(1), Value: big.NewInt(1), Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return tx
+	}
+
+	transfer := func(to common.Address) []byte {
+		return append(append(common.CopyBytes(transferMethodID), common.LeftPadBytes(to.Bytes(), 32)...), common.LeftPadBytes(big.NewInt(1).Bytes(), 32)...)
+	}
+
+	var emptyBloom, tokenBloom types.Bloom
+	tokenBloom.Add(token.Bytes())
+
+	tests := []struct {
+		name  string
+		tx    *types.Transaction
+		bloom types.Bloom
+		want  bool
+	}{
+		{"native to deposit", sign(deposit, nil), emptyBloom, true},
+		{"native to other", sign(other, nil), tokenBloom, false},
+		{"token transfer to deposit", sign(token, transfer(deposit)), tokenBloom, true},
+		{"token transfer to other", sign(token, transfer(other)), tokenBloom, false},
+		{"unknown contract with token logs", sign(router, []byte{1, 2, 3, 4}), tokenBloom, true},
+		{"unknown contract without token logs", sign(router, []byte{1, 2, 3, 4}), emptyBloom, false},
+	}
+
+	for _, test := range tests {
+		if got := b.mayConcernWatchlist(test.tx, test.bloom); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+
+	b.watchlist = watchlist.NewSet(sender.Hex())
+	if !b.mayConcernWatchlist(sign(other, nil), emptyBloom) {
+		t.Error("expected transaction from a watched sender to be relevant")
+	}
+}
@@ -3,9 +3,10 @@ package tron
 import (
 	"context"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"math/big"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,7 +21,9 @@ import (
 	"github.com/zsmartex/multichain/pkg/block"
 	"github.com/zsmartex/multichain/pkg/blockchain"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/watchlist"
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
@@ -33,6 +36,17 @@ type Blockchain struct {
 	client       *client.GrpcClient
 	walletClient api.WalletClient
 	setting      *blockchain.Setting
+	watchlist    watchlist.Watchlist
+
+	// solidityClient count confirmations up to the solidified block when the setting has a solidity_uri
+	solidityConn   *grpc.ClientConn
+	solidityClient api.WalletSolidityClient
+
+	pending blockchain.PendingTracker // the transactions seen pending, by hex id
+}
+
+func init() {
+	blockchain.Register("tron", NewBlockchain)
 }
 
 func NewBlockchain() blockchain.Blockchain {
@@ -41,34 +55,130 @@ func NewBlockchain() blockchain.Blockchain {
 	}
 }
 
-func (b *Blockchain) Configure(setting *blockchain.Setting) {
-	b.setting = setting
-	b.currencies = setting.Currencies
+func (b *Blockchain) Configure(setting *blockchain.Setting) error {
+	if err := setting.Validate(); err != nil {
+		return fmt.Errorf("failed to configure tron blockchain: %w", err)
+	}
 
-	if setting != nil {
-		if len(setting.URI) > 0 {
-			b.client = client.NewGrpcClientWithTimeout(setting.URI, 5*time.Second)
-			b.client.Start(grpc.WithInsecure())
-			b.walletClient = b.client.Client
-		}
+	if err := validateURI(setting.URI); err != nil {
+		return fmt.Errorf("failed to configure tron blockchain: %w", err)
 	}
 
+	var native *currency.Currency
+	contracts := make([]*currency.Currency, 0)
 	for _, c := range setting.Currencies {
 		if c.Options["trc20_contract_address"] != nil {
-			b.contracts = append(b.contracts, c)
+			if _, ok := c.Options["trc20_contract_address"].(string); !ok {
+				return fmt.Errorf("failed to configure tron blockchain: %w: currency %s trc20_contract_address must be a string", errors.ErrInvalidConfiguration, c.ID)
+			}
+
+			contracts = append(contracts, c)
 		} else {
-			b.currency = c
+			native = c
+		}
+	}
+
+	if native == nil {
+		return fmt.Errorf("failed to configure tron blockchain: %w: native currency is missing", errors.ErrInvalidConfiguration)
+	}
+
+	var solidityConn *grpc.ClientConn
+	if uri, ok := setting.Options["solidity_uri"]; ok {
+		s, ok := uri.(string)
+		if !ok || validateURI(s) != nil {
+			return fmt.Errorf("failed to configure tron blockchain: %w: solidity_uri %v is invalid", errors.ErrInvalidConfiguration, uri)
+		}
+
+		conn, err := grpc.Dial(s, grpc.WithInsecure())
+		if err != nil {
+			return fmt.Errorf("failed to configure tron blockchain: %w", errors.Wrap(errors.ErrInvalidConfiguration, err))
+		}
+
+		solidityConn = conn
+	}
+
+	grpcClient := client.NewGrpcClientWithTimeout(setting.URI, 5*time.Second)
+	if err := grpcClient.Start(grpc.WithInsecure()); err != nil {
+		if solidityConn != nil {
+			solidityConn.Close()
 		}
+
+		return fmt.Errorf("failed to configure tron blockchain: %w", errors.Wrap(errors.ErrInvalidConfiguration, err))
+	}
+
+	b.Close()
+
+	b.client = grpcClient
+	b.walletClient = grpcClient.Client
+	b.solidityConn = solidityConn
+	b.solidityClient = nil
+	if solidityConn != nil {
+		b.solidityClient = api.NewWalletSolidityClient(solidityConn)
 	}
+	b.setting = setting
+	b.currency = native
+	b.contracts = contracts
+	b.currencies = setting.Currencies
+	b.watchlist = setting.AddressFilter()
+
+	return nil
+}
+
+// validateURI check that uri is the host:port target of a grpc node, such as 127.0.0.1:50051
+func validateURI(uri string) error {
+	host, port, err := net.SplitHostPort(uri)
+	if err != nil {
+		return fmt.Errorf("%w: uri %s must be a host:port grpc target: %v", errors.ErrInvalidConfiguration, uri, err)
+	}
+
+	if len(host) == 0 {
+		return fmt.Errorf("%w: uri %s has no host", errors.ErrInvalidConfiguration, uri)
+	}
+
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return fmt.Errorf("%w: uri %s has an invalid port", errors.ErrInvalidConfiguration, uri)
+	}
+
+	return nil
+}
+
+func (b *Blockchain) Health(ctx context.Context) error {
+	if b.walletClient == nil {
+		return fmt.Errorf("%w: tron blockchain is not configured", errors.ErrInvalidConfiguration)
+	}
+
+	return health(ctx, b.walletClient)
+}
+
+func (b *Blockchain) Close() error {
+	if b.client != nil {
+		b.client.Stop()
+	}
+
+	if b.solidityConn != nil {
+		b.solidityConn.Close()
+		b.solidityConn = nil
+		b.solidityClient = api.NewWalletSolidityClient(closedConn{})
+	}
+
+	b.client = closedClient()
+	b.walletClient = b.client.Client
+
+	return nil
 }
 
 func (b *Blockchain) GetLatestBlockNumber(ctx context.Context) (int64, error) {
 	block, err := b.walletClient.GetNowBlock(ctx, new(api.EmptyMessage))
 	if err != nil {
-		return 0, err
+		return 0, normalizeError(err, nil)
+	}
+
+	header := block.GetBlockHeader().GetRawData()
+	if header == nil {
+		return 0, fmt.Errorf("%w: node returned an empty head block", errors.ErrNodeUnavailable)
 	}
 
-	return block.BlockHeader.RawData.Number, nil
+	return header.GetNumber(), nil
 }
 
 func (b *Blockchain) GetBlockByNumber(ctx context.Context, blockNumber int64) (*block.Block, error) {
@@ -78,7 +188,11 @@ func (b *Blockchain) GetBlockByNumber(ctx context.Context, blockNumber int64) (*
 		Num: blockNumber,
 	}, maxSizeOption)
 	if err != nil {
-		return nil, err
+		return nil, normalizeError(err, errors.ErrBlockNotFound)
+	}
+
+	if block.GetBlockHeader().GetRawData() == nil {
+		return nil, fmt.Errorf("%w: %d", errors.ErrBlockNotFound, blockNumber)
 	}
 
 	return b.buildBlock(ctx, block)
@@ -96,36 +210,57 @@ func (b *Blockchain) GetBlockByHash(ctx context.Context, hash string) (*block.Bl
 	maxSizeOption := grpc.MaxCallRecvMsgSize(32 * 10e6)
 	block, err := b.walletClient.GetBlockById(ctx, blockID, maxSizeOption)
 	if err != nil {
-		return nil, err
+		return nil, normalizeError(err, errors.ErrBlockNotFound)
+	}
+
+	if block.GetBlockHeader().GetRawData() == nil {
+		return nil, fmt.Errorf("%w: %s", errors.ErrBlockNotFound, hash)
 	}
 
 	return b.buildBlock(ctx, block)
 }
 
 func (b *Blockchain) buildBlock(ctx context.Context, blk *core.Block) (*block.Block, error) {
+	header := blk.GetBlockHeader().GetRawData()
+	if header == nil {
+		return nil, fmt.Errorf("%w: block without header", errors.ErrBlockNotFound)
+	}
+
 	transactions := make([]*transaction.Transaction, 0)
 	for _, t := range blk.Transactions {
+		if b.watchlist != nil && !b.mayConcernWatchlist(t) {
+			continue
+		}
+
 		trans, err := b.buildTransaction(ctx, t)
 		if err != nil {
 			return nil, err
 		}
 
 		for _, t2 := range trans {
-			t2.BlockNumber = blk.BlockHeader.RawData.Number
+			t2.BlockNumber = header.GetNumber()
 		}
 
-		transactions = append(transactions, trans...)
+		transactions = append(transactions, watchlist.FilterTransactions(b.watchlist, trans)...)
+	}
+
+	hash, err := concerns.BlockIDToHex(blk.BlockHeader)
+	if err != nil {
+		return nil, err
 	}
 
 	return &block.Block{
-		Number:       blk.BlockHeader.RawData.Number,
-		Transactions: transactions,
+		Hash:             hash,
+		ParentHash:       hex.EncodeToString(header.GetParentHash()),
+		Number:           header.GetNumber(),
+		Timestamp:        time.UnixMilli(header.GetTimestamp()),
+		Size:             int64(proto.Size(blk)),
+		TransactionCount: len(blk.Transactions),
+		Transactions:     transactions,
 	}, nil
 }
 
 func (b *Blockchain) buildTransaction(ctx context.Context, tx *core.Transaction) ([]*transaction.Transaction, error) {
-	transactions := make([]*transaction.Transaction, 0)
-
 	txID, err := concerns.TransactionToHex(tx)
 	if err != nil {
 		return nil, err
@@ -139,10 +274,47 @@ func (b *Blockchain) buildTransaction(ctx context.Context, tx *core.Transaction)
 
 	maxSizeOption := grpc.MaxCallRecvMsgSize(32 * 10e6)
 	txInfo, err := b.walletClient.GetTransactionInfoById(ctx, transactionID, maxSizeOption)
+	if err != nil {
+		return nil, normalizeError(err, errors.ErrTransactionNotFound)
+	}
+
+	return b.buildContractTransactions(tx, txInfo)
+}
+
+// buildPendingTransaction build the transactions of tx before it is in a block, there is no
+// transaction info yet so they are built from an empty one
+func (b *Blockchain) buildPendingTransaction(tx *core.Transaction) ([]*transaction.Transaction, error) {
+	txID, err := concerns.TransactionToHex(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	transactionID, err := common.FromHex(txID)
 	if err != nil {
 		return nil, err
 	}
 
+	transactions, err := b.buildContractTransactions(tx, &core.TransactionInfo{
+		Id:      transactionID,
+		Receipt: &core.ResourceReceipt{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	firstSeen := b.pending.FirstSeen(common.Bytes2Hex(transactionID))
+	for _, t := range transactions {
+		t.Status = transaction.StatusPending
+		t.FirstSeen = null.TimeFrom(firstSeen)
+		t.Confirmations = null.Int64From(0)
+	}
+
+	return transactions, nil
+}
+
+func (b *Blockchain) buildContractTransactions(tx *core.Transaction, txInfo *core.TransactionInfo) ([]*transaction.Transaction, error) {
+	transactions := make([]*transaction.Transaction, 0)
+
 	for _, contractTx := range tx.RawData.Contract {
 		if contractTx.Type == core.Transaction_Contract_TriggerSmartContract {
 			if b.invalidTrc20Txn(txInfo) {
@@ -208,7 +380,10 @@ func (b *Blockchain) buildTrxTransaction(contractTx *core.Transaction_Contract,
 	}, nil
 }
 
-const Trc20TransferMethodSignature = "a9059cbb"
+const (
+	Trc20TransferMethodSignature     = "a9059cbb"
+	Trc20TransferFromMethodSignature = "23b872dd"
+)
 
 func (b *Blockchain) buildTrc20Transaction(txContract *core.Transaction_Contract, txInfo *core.TransactionInfo) (*transaction.Transaction, error) {
 	if b.transactionStatus(txInfo) == transaction.StatusFailed {
@@ -223,7 +398,19 @@ func (b *Blockchain) buildTrc20Transaction(txContract *core.Transaction_Contract
 
 	dataHex := hex.EncodeToString(transferTriggerSmartContract.GetData())
 
-	if len(dataHex) != 136 && !strings.HasPrefix(dataHex, Trc20TransferMethodSignature) {
+	var fromAddress, toAddress address.Address
+	var valueStr string
+	switch {
+	case len(dataHex) >= 136 && strings.HasPrefix(dataHex, Trc20TransferMethodSignature):
+		fromAddress = address.Address(transferTriggerSmartContract.OwnerAddress)
+		toAddress = address.HexToAddress("41" + dataHex[32:72])
+		valueStr = dataHex[72:136]
+	case len(dataHex) >= 200 && strings.HasPrefix(dataHex, Trc20TransferFromMethodSignature):
+		// the owner only spends the allowance of the sender
+		fromAddress = address.HexToAddress("41" + dataHex[32:72])
+		toAddress = address.HexToAddress("41" + dataHex[96:136])
+		valueStr = dataHex[136:200]
+	default:
 		return b.buildInvalidTrc20Txn(txInfo)
 	}
 
@@ -241,10 +428,6 @@ func (b *Blockchain) buildTrc20Transaction(txContract *core.Transaction_Contract
 		return b.buildInvalidTrc20Txn(txInfo)
 	}
 
-	fromAddress := address.Address(transferTriggerSmartContract.OwnerAddress)
-	toAddress := address.HexToAddress("41" + dataHex[32:32+40])
-
-	valueStr := dataHex[64+len(Trc20TransferMethodSignature):]
 	value := new(big.Int)
 	value.SetString(valueStr, 16)
 
@@ -300,7 +483,7 @@ func (b *Blockchain) GetBalanceOfAddress(ctx context.Context, address string, cu
 	}
 
 	if c == nil {
-		return decimal.Zero, errors.New("currency not found")
+		return decimal.Zero, fmt.Errorf("%w: %s", errors.ErrUnknownCurrency, currencyID)
 	}
 
 	if c.Options["trc20_contract_address"] != nil {
@@ -313,7 +496,12 @@ func (b *Blockchain) GetBalanceOfAddress(ctx context.Context, address string, cu
 func (b *Blockchain) loadTrxBalance(ctx context.Context, address string) (decimal.Decimal, error) {
 	result, err := b.client.GetAccount(address)
 	if err != nil {
-		return decimal.Zero, nil
+		// new accounts are unknown to the node until they receive something
+		if err.Error() == "account not found" {
+			return decimal.Zero, nil
+		}
+
+		return decimal.Zero, normalizeError(err, nil)
 	}
 
 	return decimal.NewFromBigInt(big.NewInt(result.Balance), -b.currency.Subunits), nil
@@ -322,34 +510,89 @@ func (b *Blockchain) loadTrxBalance(ctx context.Context, address string) (decima
 func (b *Blockchain) loadTrc20Balance(ctx context.Context, address string, currency *currency.Currency) (decimal.Decimal, error) {
 	big, err := b.client.TRC20ContractBalance(address, currency.Options["trc20_contract_address"].(string))
 	if err != nil {
-		return decimal.Zero, nil
+		return decimal.Zero, normalizeError(err, nil)
 	}
 
 	return decimal.NewFromBigInt(big, -b.currency.Subunits), nil
 }
 
+// GetTransaction return the transactions of transactionHash, they have StatusPending while it is in the
+// pending pool of the node and the error is ErrTransactionDropped once a transaction seen pending is neither
+// there nor in a block, the error is ErrTransactionNotFound for a transaction which was never seen
 func (b *Blockchain) GetTransaction(ctx context.Context, transactionHash string) ([]*transaction.Transaction, error) {
+	tx, txInfo, err := b.lookupTransaction(ctx, transactionHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if txInfo == nil {
+		return b.buildPendingTransaction(tx)
+	}
+
+	confirmations, err := b.confirmations(ctx, txInfo.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := b.buildContractTransactions(tx, txInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range transactions {
+		t.Confirmations = null.Int64From(confirmations)
+	}
+
+	return transactions, nil
+}
+
+// lookupTransaction return the transaction of transactionHash and its info, which is nil while it is pending
+func (b *Blockchain) lookupTransaction(ctx context.Context, transactionHash string) (*core.Transaction, *core.TransactionInfo, error) {
 	var err error
 	transactionID := new(api.BytesMessage)
 	transactionID.Value, err = common.FromHex(transactionHash)
 	if err != nil {
-		return nil, fmt.Errorf("get transaction by id error: %v", err)
+		return nil, nil, fmt.Errorf("get transaction by id error: %v", err)
 	}
 
 	maxSizeOption := grpc.MaxCallRecvMsgSize(32 * 10e6)
 	tx, err := b.walletClient.GetTransactionById(ctx, transactionID, maxSizeOption)
 	if err != nil {
-		return nil, err
+		return nil, nil, normalizeError(err, errors.ErrTransactionNotFound)
 	}
 
-	if size := proto.Size(tx); size == 0 {
-		return nil, fmt.Errorf("transaction info not found")
+	if size := proto.Size(tx); size > 0 {
+		txInfo, err := b.walletClient.GetTransactionInfoById(ctx, transactionID, maxSizeOption)
+		if err != nil {
+			return nil, nil, normalizeError(err, errors.ErrTransactionNotFound)
+		}
+
+		// the info is only known once the block including the transaction is processed
+		if proto.Size(txInfo) == 0 {
+			return tx, nil, nil
+		}
+
+		b.pending.Forget(common.Bytes2Hex(transactionID.Value))
+
+		return tx, txInfo, nil
 	}
 
-	ts, err := b.buildTransaction(ctx, tx)
+	// blocks only know mined transactions, the others may still wait in the pending pool
+	tx, err = b.walletClient.GetTransactionFromPending(ctx, transactionID, maxSizeOption)
 	if err != nil {
-		return nil, err
+		return nil, nil, normalizeError(err, errors.ErrTransactionNotFound)
+	}
+
+	if size := proto.Size(tx); size > 0 {
+		b.pending.FirstSeen(common.Bytes2Hex(transactionID.Value))
+
+		return tx, nil, nil
+	}
+
+	err = fmt.Errorf("%w: %s", errors.ErrTransactionNotFound, transactionHash)
+	if b.pending.SeenPending(common.Bytes2Hex(transactionID.Value)) {
+		return nil, nil, errors.Wrap(errors.ErrTransactionDropped, err)
 	}
 
-	return ts, err
+	return nil, nil, err
 }
@@ -2,15 +2,28 @@ package tron
 
 import (
 	"context"
+	"encoding/hex"
+	"math/big"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/fbsobreira/gotron-sdk/pkg/address"
+	"github.com/fbsobreira/gotron-sdk/pkg/proto/api"
+	"github.com/fbsobreira/gotron-sdk/pkg/proto/core"
+	"github.com/volatiletech/null/v9"
+	"github.com/zsmartex/multichain/chains/tron/concerns"
 	"github.com/zsmartex/multichain/pkg/blockchain"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/watchlist"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
-func newBlockchain() blockchain.Blockchain {
+func newBlockchain(t *testing.T) blockchain.Blockchain {
 	bl := NewBlockchain()
-	bl.Configure(&blockchain.Setting{
+	if err := bl.Configure(&blockchain.Setting{
 		URI: "grpc.nile.trongrid.io:50051",
 		Currencies: []*currency.Currency{
 			{
@@ -25,68 +38,78 @@ func newBlockchain() blockchain.Blockchain {
 				},
 			},
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	return bl
 }
 
 func TestBlockchain_GetLatestBlockNumber(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	blockNumber, err := bl.GetLatestBlockNumber(context.Background())
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	t.Log(blockNumber)
 }
 
 func TestBlockchain_GetBlockByNumber(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	block, err := bl.GetBlockByNumber(context.Background(), 39870460)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	t.Log(block)
 }
 
 func TestBlockchain_GetBlockByHash(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	block, err := bl.GetBlockByHash(context.Background(), "0000000001876cb35a0f2774d2471bfe497d6c08b2857d663d2118262e585814")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	t.Log(block)
 }
 
 func TestBlockchain_GetTrxTransaction(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	tx, err := bl.GetTransaction(context.Background(), "e602558b952347dd9c9ec6f6e27a45feef97733a37820b6de074714d15e225e9")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	if len(tx) == 0 {
+		t.Fatal("expected the transaction to have transfers")
 	}
 
-	t.Error(tx[0])
+	t.Log(tx[0])
 }
 
 func TestBlockchain_GetTrc20Transaction(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	tx, err := bl.GetTransaction(context.Background(), "a9d2d659a14c402087b208fa3f7063206b441f186f86b14dd2d1d9d90313113e")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+
+	if len(tx) == 0 {
+		t.Fatal("expected the transaction to have transfers")
 	}
 
-	t.Error(tx[0])
+	t.Log(tx[0])
 }
 
 func TestBlockchain_GetBalanceOfAddress(t *testing.T) {
-	bl := newBlockchain()
+	bl := newBlockchain(t)
 
 	trxBalance, err := bl.GetBalanceOfAddress(context.Background(), "TNFUgrTZ8ks12qNrZqMMBbAq3h7Y7S4DEq", "TRX")
 	if err != nil {
@@ -101,3 +124,170 @@ func TestBlockchain_GetBalanceOfAddress(t *testing.T) {
 	t.Log(trxBalance)
 	t.Log(trc20Balance)
 }
+
+func TestBlockchain_BuildPendingTransaction(t *testing.T) {
+	b := &Blockchain{
+		currency: &currency.Currency{ID: "trx", Subunits: 6},
+	}
+
+	from, _ := address.Base58ToAddress("TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH")
+	to, _ := address.Base58ToAddress("TJRabPrwbZy45sbavfcjinPJC18kjpRTv8")
+
+	parameter, err := anypb.New(&core.TransferContract{
+		OwnerAddress: from,
+		ToAddress:    to,
+		Amount:       1500000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &core.Transaction{
+		RawData: &core.TransactionRaw{
+			Contract: []*core.Transaction_Contract{
+				{Type: core.Transaction_Contract_TransferContract, Parameter: parameter},
+			},
+			FeeLimit: 1000000,
+		},
+	}
+
+	transactions, err := b.buildPendingTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txID, err := concerns.TransactionToHex(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(transactions))
+	}
+
+	trans := transactions[0]
+	if trans.Status != transaction.StatusPending || !trans.FirstSeen.Valid || trans.Confirmations != null.Int64From(0) {
+		t.Fatalf("expected a pending transaction with a first seen time, got %s", trans.Status)
+	}
+
+	if trans.FromAddress != from.String() || trans.ToAddress != to.String() || trans.Amount.String() != "1.5" || trans.TxHash.String != txID {
+		t.Fatalf("unexpected transfer %s of %s from %s to %s", trans.TxHash.String, trans.Amount, trans.FromAddress, trans.ToAddress)
+	}
+
+	if !b.pending.SeenPending(txID) || b.pending.SeenPending("01") {
+		t.Fatal("expected only the built transaction to be seen pending")
+	}
+}
+
+func TestBlockchain_Trc20TransferFrom(t *testing.T) {
+	owner, _ := address.Base58ToAddress("TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH")
+	from, _ := address.Base58ToAddress("TJRabPrwbZy45sbavfcjinPJC18kjpRTv8")
+	to, _ := address.Base58ToAddress("TXYZopYRdj2D9XRtbG411XZZ3kM5VkAeBf")
+	contract, _ := address.Base58ToAddress("TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t")
+
+	b := &Blockchain{
+		currency:  &currency.Currency{ID: "trx", Subunits: 6},
+		contracts: []*currency.Currency{{ID: "usdt", Subunits: 6, Options: map[string]interface{}{"trc20_contract_address": contract.String()}}},
+		watchlist: watchlist.NewSet(from.String()),
+	}
+
+	data, _ := hex.DecodeString(Trc20TransferFromMethodSignature)
+	data = append(data, common.LeftPadBytes(from[1:], 32)...)
+	data = append(data, common.LeftPadBytes(to[1:], 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(2500000).Bytes(), 32)...)
+
+	parameter, err := anypb.New(&core.TriggerSmartContract{OwnerAddress: owner, ContractAddress: contract, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &core.Transaction{
+		RawData: &core.TransactionRaw{
+			Contract: []*core.Transaction_Contract{
+				{Type: core.Transaction_Contract_TriggerSmartContract, Parameter: parameter},
+			},
+		},
+	}
+
+	if !b.mayConcernWatchlist(tx) {
+		t.Fatal("expected a transferFrom of a watched sender to concern the watchlist")
+	}
+
+	trans, err := b.buildTrc20Transaction(tx.RawData.Contract[0], &core.TransactionInfo{Receipt: &core.ResourceReceipt{Result: core.Transaction_Result_SUCCESS}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trans.Currency != "usdt" || trans.FromAddress != from.String() || trans.ToAddress != to.String() || trans.Amount.String() != "2.5" {
+		t.Fatalf("unexpected transfer of %s %s from %s to %s", trans.Amount, trans.Currency, trans.FromAddress, trans.ToAddress)
+	}
+}
+
+// emptyConn answer every call with an empty message
+type emptyConn struct{}
+
+func (emptyConn) Invoke(context.Context, string, interface{}, interface{}, ...grpc.CallOption) error {
+	return nil
+}
+
+func (emptyConn) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, nil
+}
+
+func TestBlockchain_EmptyHeader(t *testing.T) {
+	b := &Blockchain{walletClient: api.NewWalletClient(emptyConn{})}
+
+	if err := b.Health(context.Background()); !errors.Is(err, errors.ErrNodeUnavailable) {
+		t.Fatalf("expected node unavailable for an empty head block, got %v", err)
+	}
+
+	if _, err := b.GetLatestBlockNumber(context.Background()); !errors.Is(err, errors.ErrNodeUnavailable) {
+		t.Fatalf("expected node unavailable for an empty head block, got %v", err)
+	}
+
+	if _, err := b.GetBlockByNumber(context.Background(), 1); !errors.Is(err, errors.ErrBlockNotFound) {
+		t.Fatalf("expected block not found for a block without header, got %v", err)
+	}
+}
+
+func TestBlockchain_Close(t *testing.T) {
+	b := NewBlockchain()
+	if err := b.Configure(&blockchain.Setting{
+		URI:        "127.0.0.1:50051",
+		Currencies: []*currency.Currency{{ID: "trx", Subunits: 6}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Health(context.Background()); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+
+	if _, err := b.GetLatestBlockNumber(context.Background()); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+
+	if _, err := b.GetBalanceOfAddress(context.Background(), "TJRabPrwbZy45sbavfcjinPJC18kjpRTv8", "trx"); !errors.Is(err, errors.ErrClosed) {
+		t.Fatalf("expected closed, got %v", err)
+	}
+}
+
+func TestValidateURI(t *testing.T) {
+	for uri, valid := range map[string]bool{
+		"127.0.0.1:50051":             true,
+		"grpc.nile.trongrid.io:50051": true,
+		"[::1]:50051":                 true,
+		"127.0.0.1":                   false,
+		"http://127.0.0.1:50051":      false,
+		":50051":                      false,
+		"127.0.0.1:port":              false,
+	} {
+		if err := validateURI(uri); (err == nil) != valid {
+			t.Errorf("validateURI(%q) = %v", uri, err)
+		}
+	}
+}
@@ -61,6 +61,13 @@ func NewFromPrivateKey(privKey string) (*Key, error) {
 	}, nil
 }
 
+func NewFromECDSA(privateKey *ecdsa.PrivateKey) *Key {
+	return &Key{
+		privateKey: privateKey,
+		publicKey:  &privateKey.PublicKey,
+	}
+}
+
 func (k *Key) Hex() string {
 	return hexutil.Encode(crypto.FromECDSA(k.privateKey))[2:]
 }
@@ -80,3 +87,40 @@ func (k *Key) Sign(rawData []byte) (signature []byte, err error) {
 
 	return signature, nil
 }
+
+// MessageHash is the hash of a TIP-191 message, as signed by TronWeb signMessageV2
+func MessageHash(message []byte) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("\x19TRON Signed Message:\n%d", len(message))), message)
+}
+
+// SignMessage sign message in the TIP-191 format, the recovery id is 27 or 28 like TronWeb
+func (k *Key) SignMessage(message []byte) (signature []byte, err error) {
+	signature, err = crypto.Sign(MessageHash(message), k.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("sign error: %v", err)
+	}
+
+	signature[crypto.RecoveryIDOffset] += 27
+
+	return signature, nil
+}
+
+// RecoverMessageAddress return the address of the key which signed message in the TIP-191 format
+func RecoverMessageAddress(message []byte, signature []byte) (address.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("signature length %d is invalid", len(signature))
+	}
+
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := crypto.SigToPub(MessageHash(message), sig)
+	if err != nil {
+		return nil, err
+	}
+
+	return address.PubkeyToAddress(*publicKey), nil
+}
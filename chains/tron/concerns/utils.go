@@ -2,6 +2,8 @@ package concerns
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 
 	"github.com/fbsobreira/gotron-sdk/pkg/common"
@@ -21,3 +23,17 @@ func TransactionToHex(tx *core.Transaction) (string, error) {
 
 	return common.BytesToHash(hash).String()[2:], nil
 }
+
+// BlockIDToHex returns the id of a block which is the sha256 of its raw header
+// with the first 8 bytes replaced by the block number
+func BlockIDToHex(header *core.BlockHeader) (string, error) {
+	rawData, err := proto.Marshal(header.GetRawData())
+	if err != nil {
+		return "", fmt.Errorf("proto marshal block header raw data error: %v", err)
+	}
+
+	hash := sha256.Sum256(rawData)
+	binary.BigEndian.PutUint64(hash[:8], uint64(header.GetRawData().GetNumber()))
+
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,48 @@
+package tron
+
+import (
+	"context"
+
+	"github.com/fbsobreira/gotron-sdk/pkg/proto/api"
+)
+
+// GetConfirmations return the number of blocks from the one of transactionHash to the head of the full node,
+// or to the solidified block when the setting has a solidity_uri so that only irreversible blocks count
+func (b *Blockchain) GetConfirmations(ctx context.Context, transactionHash string) (int64, error) {
+	_, txInfo, err := b.lookupTransaction(ctx, transactionHash)
+	if err != nil {
+		return 0, err
+	}
+
+	if txInfo == nil {
+		return 0, nil
+	}
+
+	return b.confirmations(ctx, txInfo.BlockNumber)
+}
+
+// confirmations count the blocks from blockNumber to the head of the node confirmations are counted on
+func (b *Blockchain) confirmations(ctx context.Context, blockNumber int64) (int64, error) {
+	var head int64
+	if b.solidityClient != nil {
+		block, err := b.solidityClient.GetNowBlock(ctx, new(api.EmptyMessage))
+		if err != nil {
+			return 0, normalizeError(err, nil)
+		}
+
+		head = block.GetBlockHeader().GetRawData().GetNumber()
+	} else {
+		number, err := b.GetLatestBlockNumber(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		head = number
+	}
+
+	if blockNumber > head {
+		return 0, nil
+	}
+
+	return head - blockNumber + 1, nil
+}
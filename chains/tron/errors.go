@@ -0,0 +1,100 @@
+package tron
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fbsobreira/gotron-sdk/pkg/client"
+	"github.com/fbsobreira/gotron-sdk/pkg/proto/api"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// normalizeError classify an error returned by the tron grpc api,
+// notFound is used when the node reports that the requested object does not exist
+func normalizeError(err error, notFound error) error {
+	if err == nil {
+		return nil
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return errors.Wrap(errors.ErrNodeUnavailable, err)
+	case codes.NotFound:
+		if notFound != nil {
+			return errors.Wrap(notFound, err)
+		}
+	}
+
+	return err
+}
+
+// broadcastError classify a failed broadcast result
+func broadcastError(resp *api.Return, description string) error {
+	err := fmt.Errorf("%s: %s (%s)", description, string(resp.Message), resp.Code.String())
+
+	switch resp.Code {
+	case api.Return_BANDWITH_ERROR:
+		return errors.Wrap(errors.ErrInsufficientFunds, err)
+	case api.Return_CONTRACT_VALIDATE_ERROR:
+		message := strings.ToLower(string(resp.Message))
+		if strings.Contains(message, "balance is not sufficient") || strings.Contains(message, "account resource insufficient") {
+			return errors.Wrap(errors.ErrInsufficientFunds, err)
+		}
+	case api.Return_DUP_TRANSACTION_ERROR:
+		return errors.Wrap(errors.ErrAlreadyKnown, err)
+	case api.Return_SERVER_BUSY, api.Return_NO_CONNECTION, api.Return_NOT_ENOUGH_EFFECTIVE_CONNECTION:
+		return errors.Wrap(errors.ErrNodeUnavailable, err)
+	}
+
+	return err
+}
+
+// maxHeadAge is how old the head block of a healthy node can be, tron produce a block every 3 seconds
+const maxHeadAge = time.Minute
+
+// health check that the node answers and its head block is recent
+func health(ctx context.Context, walletClient api.WalletClient) error {
+	block, err := walletClient.GetNowBlock(ctx, new(api.EmptyMessage))
+	if err != nil {
+		return normalizeError(err, nil)
+	}
+
+	header := block.GetBlockHeader().GetRawData()
+	if header == nil {
+		return fmt.Errorf("%w: node returned an empty head block", errors.ErrNodeUnavailable)
+	}
+
+	headTime := time.UnixMilli(header.GetTimestamp())
+	if age := time.Since(headTime); age > maxHeadAge {
+		return fmt.Errorf("%w: head block %d is %s old", errors.ErrNodeUnavailable, header.GetNumber(), age.Truncate(time.Second))
+	}
+
+	return nil
+}
+
+// closedConn is the connection of the clients once closed, every call fails with ErrClosed
+type closedConn struct{}
+
+func (closedConn) Invoke(context.Context, string, interface{}, interface{}, ...grpc.CallOption) error {
+	return errors.ErrClosed
+}
+
+func (closedConn) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	return nil, errors.ErrClosed
+}
+
+// closedClient return a client whose calls fail with ErrClosed, it replaces the
+// clients on Close so the calls made afterwards don't dereference a nil client
+func closedClient() *client.GrpcClient {
+	return &client.GrpcClient{Client: api.NewWalletClient(closedConn{})}
+}
@@ -0,0 +1,45 @@
+package tron
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/fbsobreira/gotron-sdk/pkg/address"
+	"github.com/zsmartex/multichain/chains/tron/concerns"
+)
+
+// SignMessage sign message with the wallet key in the TIP-191 format of TronWeb signMessageV2
+func (w *Wallet) SignMessage(_ context.Context, message []byte) (string, error) {
+	key, err := w.key(w.wallet.Secret)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := key.SignMessage(message)
+	if err != nil {
+		return "", err
+	}
+
+	return hexutil.Encode(signature), nil
+}
+
+// VerifyMessage check a TIP-191 signature of message by address
+func (w *Wallet) VerifyMessage(_ context.Context, addr string, message []byte, signature string) (bool, error) {
+	if _, err := address.Base58ToAddress(addr); err != nil {
+		return false, fmt.Errorf("address %s is invalid: %w", addr, err)
+	}
+
+	sig, err := hexutil.Decode("0x" + strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(sig) != 65 {
+		return false, fmt.Errorf("signature %s is invalid", signature)
+	}
+
+	signer, err := concerns.RecoverMessageAddress(message, sig)
+	if err != nil {
+		return false, nil
+	}
+
+	return signer.String() == addr, nil
+}
@@ -0,0 +1,41 @@
+package tron
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zsmartex/multichain/pkg/wallet"
+)
+
+func TestWallet_SignMessage(t *testing.T) {
+	w := &Wallet{
+		wallet: &wallet.SettingWallet{
+			Address: "TEy2ekxCANWh6fYUgdhmPDywW3r55ASiRy",
+			Secret:  "f2e0dc09d0bdad040e983887432203ef7f20cb105376548bb15c2ad32392d2d6",
+		},
+	}
+
+	ctx := context.Background()
+	message := []byte("proof of reserves 2024-01-01")
+
+	signature, err := w.SignMessage(ctx, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := w.VerifyMessage(ctx, w.wallet.Address, message, signature); err != nil || !ok {
+		t.Fatalf("expected the signature to verify, got %v, %v", ok, err)
+	}
+
+	if ok, _ := w.VerifyMessage(ctx, "TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH", message, signature); ok {
+		t.Fatal("expected the signature not to verify for another address")
+	}
+
+	if ok, _ := w.VerifyMessage(ctx, w.wallet.Address, []byte("tampered"), signature); ok {
+		t.Fatal("expected a tampered message to be rejected")
+	}
+
+	if _, err := w.VerifyMessage(ctx, w.wallet.Address, message, "0x1234"); err == nil {
+		t.Fatal("expected a malformed signature to be an error")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/fbsobreira/gotron-sdk/pkg/address"
@@ -16,6 +18,7 @@ import (
 	"github.com/zsmartex/mergo"
 	"github.com/zsmartex/multichain/chains/tron/concerns"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
 	"github.com/zsmartex/multichain/pkg/wallet"
 	"google.golang.org/grpc"
@@ -41,35 +44,157 @@ type Wallet struct {
 	walletClient api.WalletClient
 	currency     *currency.Currency    // selected currency for this wallet
 	wallet       *wallet.SettingWallet // selected wallet for this currency
+
+	hd        *wallet.HDSetting
+	nextIndex uint32 // next index CreateAddress derives, see wallet.HDSetting
+}
+
+func init() {
+	wallet.Register("tron", NewWallet)
 }
 
 func NewWallet() wallet.Wallet {
 	return &Wallet{}
 }
 
-func (w *Wallet) Configure(settings *wallet.Setting) {
-	if settings == nil {
-		return
+func (w *Wallet) Configure(settings *wallet.Setting) error {
+	if err := settings.Validate(); err != nil {
+		return fmt.Errorf("failed to configure tron wallet: %w", err)
 	}
 
 	if settings.Wallet != nil {
-		if len(settings.Wallet.URI) > 0 {
-			w.client = client.NewGrpcClientWithTimeout(settings.Wallet.URI, 5*time.Second)
-			w.client.Start(grpc.WithInsecure())
-			w.walletClient = w.client.Client
+		if err := validateURI(settings.Wallet.URI); err != nil {
+			return fmt.Errorf("failed to configure tron wallet: %w", err)
 		}
 	}
 
 	if settings.Currency != nil {
-		w.currency = settings.Currency
+		if contractAddress, ok := settings.Currency.Options["trc20_contract_address"]; ok {
+			s, ok := contractAddress.(string)
+			if !ok {
+				return fmt.Errorf("failed to configure tron wallet: %w: currency %s trc20_contract_address must be a string", errors.ErrInvalidConfiguration, settings.Currency.ID)
+			}
+
+			if _, err := address.Base58ToAddress(s); err != nil {
+				return fmt.Errorf("failed to configure tron wallet: %w: currency %s trc20_contract_address is invalid: %v", errors.ErrInvalidConfiguration, settings.Currency.ID, err)
+			}
+		}
+	}
+
+	if settings.HD != nil {
+		w.hd = settings.HD
+		atomic.StoreUint32(&w.nextIndex, settings.HD.NextIndex)
 	}
 
 	if settings.Wallet != nil {
+		if err := w.validateSecret(settings.Wallet); err != nil {
+			return fmt.Errorf("failed to configure tron wallet: %w", errors.Wrap(errors.ErrInvalidConfiguration, err))
+		}
+
+		grpcClient := client.NewGrpcClientWithTimeout(settings.Wallet.URI, 5*time.Second)
+		if err := grpcClient.Start(grpc.WithInsecure()); err != nil {
+			return fmt.Errorf("failed to configure tron wallet: %w", errors.Wrap(errors.ErrInvalidConfiguration, err))
+		}
+
+		if w.client != nil {
+			w.client.Stop()
+		}
+
+		w.client = grpcClient
+		w.walletClient = grpcClient.Client
 		w.wallet = settings.Wallet
 	}
+
+	if settings.Currency != nil {
+		w.currency = settings.Currency
+	}
+
+	return nil
+}
+
+// validateSecret check that secret is a private key of the wallet address
+func (w *Wallet) validateSecret(settingWallet *wallet.SettingWallet) error {
+	if len(settingWallet.Address) > 0 {
+		if _, err := address.Base58ToAddress(settingWallet.Address); err != nil {
+			return fmt.Errorf("wallet address %s is invalid: %w", settingWallet.Address, err)
+		}
+	}
+
+	if len(settingWallet.Secret) == 0 {
+		return nil
+	}
+
+	key, err := w.key(settingWallet.Secret)
+	if err != nil {
+		return errors.New("wallet secret is not a valid private key")
+	}
+
+	if len(settingWallet.Address) > 0 && key.Address().String() != settingWallet.Address {
+		return fmt.Errorf("wallet secret does not belong to address %s", settingWallet.Address)
+	}
+
+	return nil
+}
+
+func (w *Wallet) Health(ctx context.Context) error {
+	if w.walletClient == nil {
+		return fmt.Errorf("%w: tron wallet is not configured", errors.ErrInvalidConfiguration)
+	}
+
+	return health(ctx, w.walletClient)
+}
+
+func (w *Wallet) Close() error {
+	if w.client != nil {
+		w.client.Stop()
+	}
+
+	w.client = closedClient()
+	w.walletClient = w.client.Client
+
+	return nil
+}
+
+// key return the key of secret, which is the derivation index of the key when the wallet is HD
+func (w *Wallet) key(secret string) (*concerns.Key, error) {
+	if index, ok := wallet.ParseIndex(secret); ok && w.hd != nil {
+		privateKey, err := w.hd.DeriveKey(w.hd.DerivationPath(44, 195), index)
+		if err != nil {
+			return nil, err
+		}
+
+		return concerns.NewFromECDSA(privateKey), nil
+	}
+
+	return concerns.NewFromPrivateKey(secret)
+}
+
+// DeriveAddress return the address at index of the HD wallet
+func (w *Wallet) DeriveAddress(_ context.Context, index uint32) (string, error) {
+	if w.hd == nil {
+		return "", fmt.Errorf("%w: tron wallet is not hd", errors.ErrInvalidConfiguration)
+	}
+
+	key, err := w.key(strconv.FormatUint(uint64(index), 10))
+	if err != nil {
+		return "", err
+	}
+
+	return key.Address().String(), nil
 }
 
 func (w *Wallet) CreateAddress(ctx context.Context) (address, secret string, err error) {
+	if w.hd != nil {
+		index := atomic.AddUint32(&w.nextIndex, 1) - 1
+
+		address, err := w.DeriveAddress(ctx, index)
+		if err != nil {
+			return "", "", err
+		}
+
+		return address, strconv.FormatUint(uint64(index), 10), nil
+	}
+
 	key, err := concerns.NewKey()
 	if err != nil {
 		return "", "", err
@@ -81,7 +206,7 @@ func (w *Wallet) CreateAddress(ctx context.Context) (address, secret string, err
 func (w *Wallet) PrepareDepositCollection(ctx context.Context, tx *transaction.Transaction, depositSpreads []*transaction.Transaction, depositCurrency *currency.Currency) (*transaction.Transaction, error) {
 	options := w.mergeOptions(defaultTrc20Fee, depositCurrency.Options)
 	if len(options.Trc20ContractAddress) == 0 {
-		return nil, nil
+		return nil, fmt.Errorf("%w: deposit of %s needs no collection fee", errors.ErrNotSupported, depositCurrency.ID)
 	}
 
 	fees := w.ConvertFromBaseUnit(options.FeeLimit)
@@ -126,7 +251,7 @@ func (w *Wallet) createTrxTransaction(ctx context.Context, tx *transaction.Trans
 		Amount:       amount.BigInt().Int64(),
 	})
 	if err != nil {
-		return nil, err
+		return nil, normalizeError(err, nil)
 	}
 
 	signedTxn, err := w.signTransaction(ctx, transactionData, w.wallet.Secret)
@@ -141,11 +266,11 @@ func (w *Wallet) createTrxTransaction(ctx context.Context, tx *transaction.Trans
 
 	resp, err := w.walletClient.BroadcastTransaction(ctx, signedTxn)
 	if err != nil {
-		return nil, err
+		return nil, normalizeError(err, nil)
 	}
 
 	if !resp.Result {
-		return nil, fmt.Errorf("failed to create trx transaction from %s to %s", w.wallet.Address, tx.ToAddress)
+		return nil, broadcastError(resp, fmt.Sprintf("failed to create trx transaction from %s to %s", w.wallet.Address, tx.ToAddress))
 	}
 
 	tx.Currency = w.currency.ID
@@ -164,7 +289,7 @@ func (w *Wallet) createTrc20Transaction(ctx context.Context, tx *transaction.Tra
 
 	resp, err := w.client.TRC20Send(w.wallet.Address, tx.ToAddress, options.Trc20ContractAddress, amount.BigInt(), options.FeeLimit.BigInt().Int64())
 	if err != nil {
-		return nil, err
+		return nil, normalizeError(err, nil)
 	}
 
 	signedTxn, err := w.signTransaction(ctx, resp.Transaction, w.wallet.Secret)
@@ -179,11 +304,11 @@ func (w *Wallet) createTrc20Transaction(ctx context.Context, tx *transaction.Tra
 
 	respBroadcast, err := w.walletClient.BroadcastTransaction(ctx, signedTxn)
 	if err != nil {
-		return nil, err
+		return nil, normalizeError(err, nil)
 	}
 
 	if !respBroadcast.Result {
-		return nil, fmt.Errorf("failed to create trc20 transaction from %s to %s", w.wallet.Address, tx.ToAddress)
+		return nil, broadcastError(respBroadcast, fmt.Sprintf("failed to create trc20 transaction from %s to %s", w.wallet.Address, tx.ToAddress))
 	}
 
 	tx.Fee = decimal.NewNullDecimal(w.ConvertFromBaseUnit(options.FeeLimit))
@@ -193,8 +318,8 @@ func (w *Wallet) createTrc20Transaction(ctx context.Context, tx *transaction.Tra
 	return tx, nil
 }
 
-func (w *Wallet) signTransaction(ctx context.Context, txData *core.Transaction, privateKey string) (transaction *core.Transaction, err error) {
-	key, err := concerns.NewFromPrivateKey(privateKey)
+func (w *Wallet) signTransaction(ctx context.Context, txData *core.Transaction, secret string) (transaction *core.Transaction, err error) {
+	key, err := w.key(secret)
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +350,12 @@ func (w *Wallet) LoadBalance(ctx context.Context) (decimal.Decimal, error) {
 func (w *Wallet) loadTrxBalance(ctx context.Context) (decimal.Decimal, error) {
 	result, err := w.client.GetAccount(w.wallet.Address)
 	if err != nil {
-		return decimal.Zero, nil
+		// new accounts are unknown to the node until they receive something
+		if err.Error() == "account not found" {
+			return decimal.Zero, nil
+		}
+
+		return decimal.Zero, normalizeError(err, nil)
 	}
 
 	amount := decimal.NewFromInt(result.Balance)
@@ -236,7 +366,7 @@ func (w *Wallet) loadTrxBalance(ctx context.Context) (decimal.Decimal, error) {
 func (w *Wallet) loadTrc20Balance(ctx context.Context) (decimal.Decimal, error) {
 	big, err := w.client.TRC20ContractBalance(w.wallet.Address, w.currency.Options["trc20_contract_address"].(string))
 	if err != nil {
-		return decimal.Zero, nil
+		return decimal.Zero, normalizeError(err, nil)
 	}
 
 	return decimal.NewFromBigInt(big, -w.currency.Subunits), nil
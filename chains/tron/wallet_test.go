@@ -11,29 +11,33 @@ import (
 	"github.com/zsmartex/multichain/pkg/wallet"
 )
 
-func newWallet() wallet.Wallet {
+func newWallet(t *testing.T) wallet.Wallet {
 	w := NewWallet()
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Wallet: &wallet.SettingWallet{
 			URI:     "grpc.nile.trongrid.io:50051",
 			Address: "TEy2ekxCANWh6fYUgdhmPDywW3r55ASiRy",
 			Secret:  "f2e0dc09d0bdad040e983887432203ef7f20cb105376548bb15c2ad32392d2d6",
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	return w
 }
 
 func TestWallet_LoadTrxBalance(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "TRX",
 			Subunits: 6,
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	balance, err := w.LoadBalance(context.Background())
 	if err != nil {
@@ -44,9 +48,9 @@ func TestWallet_LoadTrxBalance(t *testing.T) {
 }
 
 func TestWallet_LoadTrc20Balance(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "USDT",
 			Subunits: 6,
@@ -54,7 +58,9 @@ func TestWallet_LoadTrc20Balance(t *testing.T) {
 				"trc20_contract_address": "TXLAQ63Xg1NAzckPwKHvzw7CSEmLMEqcdj",
 			},
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	balance, err := w.LoadBalance(context.Background())
 	if err != nil {
@@ -65,14 +71,16 @@ func TestWallet_LoadTrc20Balance(t *testing.T) {
 }
 
 func TestWallet_CreateAddress(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "TRX",
 			Subunits: 6,
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	address, secret, err := w.CreateAddress(context.Background())
 	if err != nil {
@@ -83,14 +91,16 @@ func TestWallet_CreateAddress(t *testing.T) {
 }
 
 func TestWallet_CreateTrxTransaction(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "TRX",
 			Subunits: 6,
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	tx, err := w.CreateTransaction(context.Background(), &transaction.Transaction{
 		ToAddress: "TGKFmSijnD6iNLgaf7CbQVysw81MTDbvHq",
@@ -107,9 +117,9 @@ func TestWallet_CreateTrxTransaction(t *testing.T) {
 }
 
 func TestWallet_CreateTrc20Transaction(t *testing.T) {
-	w := newWallet()
+	w := newWallet(t)
 
-	w.Configure(&wallet.Setting{
+	if err := w.Configure(&wallet.Setting{
 		Currency: &currency.Currency{
 			ID:       "USDT",
 			Subunits: 6,
@@ -117,7 +127,9 @@ func TestWallet_CreateTrc20Transaction(t *testing.T) {
 				"trc20_contract_address": "TXLAQ63Xg1NAzckPwKHvzw7CSEmLMEqcdj",
 			},
 		},
-	})
+	}); err != nil {
+		t.Fatal(err)
+	}
 
 	tx, err := w.CreateTransaction(context.Background(), &transaction.Transaction{
 		ToAddress:   "TGKFmSijnD6iNLgaf7CbQVysw81MTDbvHq",
@@ -132,3 +144,29 @@ func TestWallet_CreateTrc20Transaction(t *testing.T) {
 	t.Log(tx)
 	t.Fail()
 }
+
+func TestWallet_DeriveAddress(t *testing.T) {
+	w := NewWallet().(*Wallet)
+
+	if err := w.Configure(&wallet.Setting{
+		HD: &wallet.HDSetting{
+			Master: "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	address, secret, err := w.CreateAddress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := w.key(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if address != "TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH" || secret != "0" || key.Address().String() != address {
+		t.Fatalf("unexpected address %s with secret %s", address, secret)
+	}
+}
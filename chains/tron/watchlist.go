@@ -0,0 +1,57 @@
+package tron
+
+import (
+	"encoding/hex"
+
+	"github.com/fbsobreira/gotron-sdk/pkg/address"
+	"github.com/fbsobreira/gotron-sdk/pkg/proto/core"
+	"github.com/zsmartex/multichain/pkg/watchlist"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// mayConcernWatchlist tell from the contract parameters whether tx could concern a watched address
+// so the GetTransactionInfoById call of every other transaction is skipped
+func (b *Blockchain) mayConcernWatchlist(tx *core.Transaction) bool {
+	for _, contractTx := range tx.RawData.Contract {
+		switch contractTx.Type {
+		case core.Transaction_Contract_TransferContract:
+			var transferContract core.TransferContract
+			if err := anypb.UnmarshalTo(contractTx.GetParameter(), &transferContract, proto.UnmarshalOptions{}); err != nil {
+				return true
+			}
+
+			if watchlist.ContainsAny(b.watchlist, address.Address(transferContract.OwnerAddress).String(), address.Address(transferContract.ToAddress).String()) {
+				return true
+			}
+		case core.Transaction_Contract_TriggerSmartContract:
+			var triggerSmartContract core.TriggerSmartContract
+			if err := proto.Unmarshal(contractTx.Parameter.GetValue(), &triggerSmartContract); err != nil {
+				return true
+			}
+
+			if b.watchlist.Contains(address.Address(triggerSmartContract.OwnerAddress).String()) {
+				return true
+			}
+
+			data := triggerSmartContract.GetData()
+			if len(data) >= 36 && hex.EncodeToString(data[:4]) == Trc20TransferMethodSignature {
+				toAddress := address.HexToAddress("41" + hex.EncodeToString(data[16:36]))
+				if b.watchlist.Contains(toAddress.String()) {
+					return true
+				}
+			}
+
+			// transferFrom moves the tokens of the sender which may be watched rather than the owner
+			if len(data) >= 68 && hex.EncodeToString(data[:4]) == Trc20TransferFromMethodSignature {
+				fromAddress := address.HexToAddress("41" + hex.EncodeToString(data[16:36]))
+				toAddress := address.HexToAddress("41" + hex.EncodeToString(data[48:68]))
+				if watchlist.ContainsAny(b.watchlist, fromAddress.String(), toAddress.String()) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -1,11 +1,17 @@
 package block
 
 import (
+	"time"
+
 	"github.com/zsmartex/multichain/pkg/transaction"
 )
 
 type Block struct {
-	Hash         string
-	Number       int64
-	Transactions []*transaction.Transaction
+	Hash             string
+	ParentHash       string
+	Number           int64
+	Timestamp        time.Time
+	Size             int64 // in bytes, 0 when the node doesn't report it
+	TransactionCount int   // all transactions of the block, not only the ones in Transactions
+	Transactions     []*transaction.Transaction
 }
@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PendingExpiry is how long a pending transaction is remembered after it was last seen, a
+// transaction which is forgotten is reported as not found instead of dropped
+const PendingExpiry = 24 * time.Hour
+
+// pendingSweepInterval is how often the expired pending transactions are removed
+const pendingSweepInterval = time.Hour
+
+// pendingSeen is when a pending transaction was first and last seen
+type pendingSeen struct {
+	first time.Time
+	last  int64 // unix nanoseconds, updated atomically
+}
+
+// PendingTracker remember the transactions a blockchain saw pending, so one which leaves
+// the mempool without being mined is reported as ErrTransactionDropped instead of not found.
+// The zero value is ready to use and it is safe for concurrent use.
+type PendingTracker struct {
+	seen  sync.Map // transaction hash -> *pendingSeen
+	swept int64    // unix nanoseconds the expired transactions were last removed
+}
+
+// FirstSeen record that hash is pending and return when it was first seen,
+// most nodes don't tell when a transaction entered their mempool
+func (p *PendingTracker) FirstSeen(hash string) time.Time {
+	now := time.Now()
+
+	value, loaded := p.seen.LoadOrStore(hash, &pendingSeen{first: now, last: now.UnixNano()})
+	seen := value.(*pendingSeen)
+	if loaded {
+		atomic.StoreInt64(&seen.last, now.UnixNano())
+	} else {
+		p.expire(now)
+	}
+
+	return seen.first
+}
+
+// SeenPending tell whether hash was seen pending within PendingExpiry, it stays known
+// until it expires so a dropped transaction is reported as such every time
+func (p *PendingTracker) SeenPending(hash string) bool {
+	value, ok := p.seen.Load(hash)
+	if !ok {
+		return false
+	}
+
+	return time.Since(time.Unix(0, atomic.LoadInt64(&value.(*pendingSeen).last))) < PendingExpiry
+}
+
+// Forget remove hash, once it is mined it can't be dropped anymore
+func (p *PendingTracker) Forget(hash string) {
+	p.seen.Delete(hash)
+}
+
+// expire remove the transactions not seen for PendingExpiry, at most once per pendingSweepInterval
+func (p *PendingTracker) expire(now time.Time) {
+	swept := atomic.LoadInt64(&p.swept)
+	if now.UnixNano()-swept < int64(pendingSweepInterval) || !atomic.CompareAndSwapInt64(&p.swept, swept, now.UnixNano()) {
+		return
+	}
+
+	p.seen.Range(func(key, value interface{}) bool {
+		if now.Sub(time.Unix(0, atomic.LoadInt64(&value.(*pendingSeen).last))) >= PendingExpiry {
+			p.seen.Delete(key)
+		}
+
+		return true
+	})
+}
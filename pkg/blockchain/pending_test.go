@@ -0,0 +1,42 @@
+package blockchain
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPendingTracker(t *testing.T) {
+	var p PendingTracker
+
+	if p.SeenPending("01") {
+		t.Fatal("expected a transaction never seen not to be seen pending")
+	}
+
+	first := p.FirstSeen("01")
+	if again := p.FirstSeen("01"); !again.Equal(first) {
+		t.Fatalf("expected the first seen time %v to stay, got %v", first, again)
+	}
+
+	if !p.SeenPending("01") || p.SeenPending("02") {
+		t.Fatal("expected only the seen transaction to be seen pending")
+	}
+
+	p.Forget("01")
+	if p.SeenPending("01") {
+		t.Fatal("expected a forgotten transaction not to be seen pending")
+	}
+
+	// a transaction not seen for PendingExpiry is forgotten
+	p.FirstSeen("02")
+	value, _ := p.seen.Load("02")
+	atomic.StoreInt64(&value.(*pendingSeen).last, time.Now().Add(-PendingExpiry).UnixNano())
+	if p.SeenPending("02") {
+		t.Fatal("expected an expired transaction not to be seen pending")
+	}
+
+	p.expire(time.Now().Add(pendingSweepInterval))
+	if _, ok := p.seen.Load("02"); ok {
+		t.Fatal("expected the expired transaction to be removed")
+	}
+}
@@ -0,0 +1,59 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory builds a new, unconfigured Blockchain driver
+type Factory func() Blockchain
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Factory)
+)
+
+// Register makes a blockchain driver available by the provided name.
+// It panics if Register is called twice with the same name or if factory is nil.
+func Register(name string, factory Factory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if factory == nil {
+		panic("blockchain: Register factory is nil")
+	}
+
+	if _, dup := drivers[name]; dup {
+		panic("blockchain: Register called twice for driver " + name)
+	}
+
+	drivers[name] = factory
+}
+
+// New create a new Blockchain from the driver registered by name
+func New(name string) (Blockchain, error) {
+	driversMu.RLock()
+	factory, ok := drivers[name]
+	driversMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("blockchain: unknown driver %q (forgotten import?)", name)
+	}
+
+	return factory(), nil
+}
+
+// Drivers returns a sorted list of the names of the registered drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	list := make([]string, 0, len(drivers))
+	for name := range drivers {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return list
+}
@@ -0,0 +1,40 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+func TestRegistry_New(t *testing.T) {
+	Register("test-registry", func() Blockchain { return nil })
+
+	if _, err := New("test-registry"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New("test-unknown"); err == nil {
+		t.Fatal("expected error for unknown driver")
+	}
+
+	found := false
+	for _, name := range Drivers() {
+		if name == "test-registry" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatal("registered driver not listed")
+	}
+}
+
+func TestRegistry_RegisterTwice(t *testing.T) {
+	Register("test-twice", func() Blockchain { return nil })
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on duplicate register")
+		}
+	}()
+
+	Register("test-twice", func() Blockchain { return nil })
+}
@@ -2,25 +2,93 @@ package blockchain
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/shopspring/decimal"
 
 	"github.com/zsmartex/multichain/pkg/block"
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
+	"github.com/zsmartex/multichain/pkg/watchlist"
 )
 
 type Setting struct {
 	Currencies           []*currency.Currency
 	WhitelistedAddresses []string
 	URI                  string
+
+	// Options hold the driver specific settings such as the scan mode
+	Options map[string]interface{}
+
+	// Watchlist takes precedence over WhitelistedAddresses, it lets the caller
+	// share a big set of deposit addresses between drivers and update it at runtime
+	Watchlist watchlist.Watchlist
+}
+
+// AddressFilter return the addresses blocks are filtered on or nil when every transaction must be returned
+func (s *Setting) AddressFilter() watchlist.Watchlist {
+	if s.Watchlist != nil {
+		return s.Watchlist
+	}
+
+	if len(s.WhitelistedAddresses) > 0 {
+		return watchlist.NewSet(s.WhitelistedAddresses...)
+	}
+
+	return nil
+}
+
+// Validate check that setting has everything a driver needs to be configured,
+// the format of the uri depends on the driver which checks it
+func (s *Setting) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: setting is nil", errors.ErrInvalidConfiguration)
+	}
+
+	if len(s.URI) == 0 {
+		return fmt.Errorf("%w: setting uri is empty", errors.ErrInvalidConfiguration)
+	}
+
+	if len(s.Currencies) == 0 {
+		return fmt.Errorf("%w: setting currencies is empty", errors.ErrInvalidConfiguration)
+	}
+
+	for _, c := range s.Currencies {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 type Blockchain interface {
-	Configure(setting *Setting)
+	Configure(setting *Setting) error
 	GetLatestBlockNumber(ctx context.Context) (int64, error)
+
+	// GetBlockByHash and GetBlockByNumber only return the transactions of the
+	// AddressFilter addresses when the setting has one
 	GetBlockByHash(ctx context.Context, hash string) (*block.Block, error)
 	GetBlockByNumber(ctx context.Context, blockNumber int64) (*block.Block, error)
+	// GetTransaction return StatusPending transactions while transactionHash is in the mempool, and
+	// errors.ErrTransactionNotFound when it is neither in the mempool nor in a block, which is
+	// errors.ErrTransactionDropped when the transaction was seen pending before
 	GetTransaction(ctx context.Context, transactionHash string) ([]*transaction.Transaction, error)
+	// GetConfirmations return the number of blocks from the one of transactionHash to the head,
+	// 1 once it is in the head block and 0 while it is pending
+	GetConfirmations(ctx context.Context, transactionHash string) (int64, error)
 	GetBalanceOfAddress(ctx context.Context, address string, currencyID string) (decimal.Decimal, error)
+
+	// Health check that the node is reachable and able to serve requests
+	Health(ctx context.Context) error
+
+	// Close release the connections held by the driver, Configure must be called before using it again
+	Close() error
+}
+
+// Validator is implemented by drivers able to check their setting against the chain,
+// the scanner refuses to run when it fails
+type Validator interface {
+	Validate(ctx context.Context) error
 }
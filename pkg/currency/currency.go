@@ -1,7 +1,30 @@
 package currency
 
+import (
+	"fmt"
+
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
 type Currency struct {
 	ID       string
 	Subunits int32 // 8 -> 18
 	Options  map[string]interface{}
 }
+
+// Validate check that currency has an id and a sane subunits
+func (c *Currency) Validate() error {
+	if c == nil {
+		return fmt.Errorf("%w: currency is nil", errors.ErrInvalidConfiguration)
+	}
+
+	if len(c.ID) == 0 {
+		return fmt.Errorf("%w: currency id is empty", errors.ErrInvalidConfiguration)
+	}
+
+	if c.Subunits < 0 || c.Subunits > 77 {
+		return fmt.Errorf("%w: currency %s subunits %d is out of range", errors.ErrInvalidConfiguration, c.ID, c.Subunits)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package errors contains the errors shared by all of the chain drivers.
+//
+// Drivers translate node specific failures (geth, bitcoind, tron grpc) into
+// one of the sentinel errors below, the original error is kept and can still
+// be reached with As or Unwrap:
+//
+//	if errors.Is(err, errors.ErrNodeUnavailable) {
+//		// retry later
+//	}
+package errors
+
+import (
+	"errors"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrTransactionDropped is returned by GetTransaction when a transaction seen pending is neither
+	// in the mempool nor in a block anymore, it is also an ErrTransactionNotFound
+	ErrTransactionDropped     = error(droppedError{})
+	ErrBlockNotFound          = errors.New("block not found")
+	ErrInsufficientFunds      = errors.New("insufficient funds")
+	ErrNonceTooLow            = errors.New("nonce too low")
+	ErrReplacementUnderpriced = errors.New("replacement transaction underpriced")
+	ErrAlreadyKnown           = errors.New("transaction already known")
+	ErrUnknownCurrency        = errors.New("unknown currency")
+	ErrNodeUnavailable        = errors.New("node unavailable")
+	ErrInvalidConfiguration   = errors.New("invalid configuration")
+	// ErrTransactionNotPending is returned when replacing a transaction which already left the mempool
+	ErrTransactionNotPending = errors.New("transaction is not pending")
+	// ErrClosed is returned by the calls made after the driver was closed
+	ErrClosed = errors.New("client is closed")
+	// ErrNotSupported is returned when a driver has nothing to do for a call with the given currency
+	ErrNotSupported = errors.New("not supported")
+)
+
+// droppedError is the type of ErrTransactionDropped, which is a kind of ErrTransactionNotFound
+type droppedError struct{}
+
+func (droppedError) Error() string {
+	return "transaction dropped"
+}
+
+func (droppedError) Is(target error) bool {
+	return target == ErrTransactionNotFound
+}
+
+// Error is an error returned by a chain node classified as one of the sentinel errors
+type Error struct {
+	Kind error // sentinel error used for Is comparisons
+	Err  error // original error returned by the node
+}
+
+// Wrap classify err as kind, returns nil if err is nil
+func Wrap(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of e or an error the kind is, like ErrTransactionNotFound
+// for ErrTransactionDropped
+func (e *Error) Is(target error) bool {
+	return e.Kind == target || errors.Is(e.Kind, target)
+}
+
+// New returns an error that formats as the given text, same as the standard library
+func New(text string) error {
+	return errors.New(text)
+}
+
+// Is reports whether any error in err's chain matches target, same as the standard library
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target, same as the standard library
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+// Unwrap returns the result of calling the Unwrap method on err, same as the standard library
+func Unwrap(err error) error {
+	return errors.Unwrap(err)
+}
@@ -0,0 +1,47 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	original := New("connection refused")
+	err := fmt.Errorf("failed to get block: %w", Wrap(ErrNodeUnavailable, original))
+
+	if !Is(err, ErrNodeUnavailable) {
+		t.Fatal("expected err to be ErrNodeUnavailable")
+	}
+
+	if !Is(err, original) {
+		t.Fatal("expected err to keep the original error")
+	}
+
+	if Is(err, ErrBlockNotFound) {
+		t.Fatal("expected err not to be ErrBlockNotFound")
+	}
+
+	var e *Error
+	if !As(err, &e) || e.Kind != ErrNodeUnavailable {
+		t.Fatal("expected err to be an *Error")
+	}
+
+	if Wrap(ErrNodeUnavailable, nil) != nil {
+		t.Fatal("expected wrap of nil to be nil")
+	}
+}
+
+func TestTransactionDropped(t *testing.T) {
+	if !Is(ErrTransactionDropped, ErrTransactionNotFound) {
+		t.Fatal("expected ErrTransactionDropped to be ErrTransactionNotFound")
+	}
+
+	if Is(ErrTransactionNotFound, ErrTransactionDropped) {
+		t.Fatal("expected ErrTransactionNotFound not to be ErrTransactionDropped")
+	}
+
+	err := fmt.Errorf("failed to get transaction: %w", Wrap(ErrTransactionDropped, New("unknown transaction")))
+	if !Is(err, ErrTransactionDropped) || !Is(err, ErrTransactionNotFound) {
+		t.Fatal("expected a wrapped ErrTransactionDropped to be ErrTransactionNotFound")
+	}
+}
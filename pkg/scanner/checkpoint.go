@@ -0,0 +1,254 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// Checkpoint is where a scanner resume after a restart
+type Checkpoint struct {
+	// Height and Hash are the last block whose transactions have all been emitted
+	Height int64  `json:"height"`
+	Hash   string `json:"hash"`
+	// Tip and TipHash are the last scanned block, transactions between Height and Tip
+	// which already had enough confirmations at Tip are not emitted again
+	Tip     int64  `json:"tip,omitempty"`
+	TipHash string `json:"tip_hash,omitempty"`
+	// Hashes are the hashes of the blocks after Height up to Tip, they are walked back
+	// to find where the chain forked when TipHash is orphaned after a restart
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+// hash returns the stored hash of block number, empty when it is unknown
+func (c *Checkpoint) hash(number int64) string {
+	if i := number - c.Height - 1; i >= 0 && i < int64(len(c.Hashes)) {
+		return c.Hashes[i]
+	}
+
+	if number == c.Tip {
+		return c.TipHash
+	}
+
+	return ""
+}
+
+func (c *Checkpoint) equal(other *Checkpoint) bool {
+	if c.Height != other.Height || c.Hash != other.Hash || c.Tip != other.Tip || c.TipHash != other.TipHash {
+		return false
+	}
+
+	if len(c.Hashes) != len(other.Hashes) {
+		return false
+	}
+
+	for i := range c.Hashes {
+		if c.Hashes[i] != other.Hashes[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Scan scan value into Checkpoint, implements sql.Scanner interface
+func (c *Checkpoint) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("failed to unmarshal checkpoint value: %v", value)
+	}
+
+	result := Checkpoint{}
+	err := json.Unmarshal(bytes, &result)
+	*c = result
+	return err
+}
+
+// Value return json value, implement driver.Valuer interface
+func (c Checkpoint) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// CheckpointStore persist the checkpoint of every chain
+type CheckpointStore interface {
+	// Load returns nil without error when the chain has no checkpoint yet
+	Load(ctx context.Context, chain string) (*Checkpoint, error)
+	Save(ctx context.Context, chain string, checkpoint *Checkpoint) error
+}
+
+// FileCheckpointStore keep the checkpoints of all chains in a single json file
+type FileCheckpointStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{
+		path: path,
+	}
+}
+
+func (s *FileCheckpointStore) Load(_ context.Context, chain string) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return checkpoints[chain], nil
+}
+
+func (s *FileCheckpointStore) Save(_ context.Context, chain string, checkpoint *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	checkpoints[chain] = checkpoint
+
+	bytes, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write a temporary file and rename it so a crash never leaves a truncated file
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(bytes); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), s.path)
+}
+
+func (s *FileCheckpointStore) read() (map[string]*Checkpoint, error) {
+	checkpoints := make(map[string]*Checkpoint)
+
+	bytes, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoints, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(bytes, &checkpoints); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints from %s: %w", s.path, err)
+	}
+
+	return checkpoints, nil
+}
+
+// Dialect select the placeholders and the upsert syntax of the database
+type Dialect string
+
+const (
+	DialectMySQL    Dialect = "mysql"
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// SQLCheckpointStore keep the checkpoints in a table like:
+//
+//	CREATE TABLE scanner_checkpoints (
+//		chain      VARCHAR(64) PRIMARY KEY,
+//		checkpoint TEXT NOT NULL
+//	);
+type SQLCheckpointStore struct {
+	db      *sql.DB
+	table   string
+	dialect Dialect
+}
+
+func NewSQLCheckpointStore(db *sql.DB, table string, dialect Dialect) *SQLCheckpointStore {
+	return &SQLCheckpointStore{
+		db:      db,
+		table:   table,
+		dialect: dialect,
+	}
+}
+
+func (s *SQLCheckpointStore) Load(ctx context.Context, chain string) (*Checkpoint, error) {
+	var checkpoint Checkpoint
+	err := s.db.
+		QueryRowContext(ctx, s.query("SELECT checkpoint FROM %s WHERE chain = ?"), chain).
+		Scan(&checkpoint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+// Save insert or update the checkpoint of chain in a single statement so concurrent
+// saves of a chain without checkpoint never race on the primary key
+func (s *SQLCheckpointStore) Save(ctx context.Context, chain string, checkpoint *Checkpoint) error {
+	var format string
+	switch s.dialect {
+	case DialectMySQL:
+		format = "INSERT INTO %s (chain, checkpoint) VALUES (?, ?) ON DUPLICATE KEY UPDATE checkpoint = VALUES(checkpoint)"
+	case DialectPostgres, DialectSQLite:
+		format = "INSERT INTO %s (chain, checkpoint) VALUES (?, ?) ON CONFLICT (chain) DO UPDATE SET checkpoint = excluded.checkpoint"
+	default:
+		return fmt.Errorf("unknown sql dialect %q", s.dialect)
+	}
+
+	_, err := s.db.ExecContext(ctx, s.query(format), chain, checkpoint)
+
+	return err
+}
+
+// query insert the table name and rewrite the placeholders for the database
+func (s *SQLCheckpointStore) query(format string) string {
+	query := fmt.Sprintf(format, s.table)
+	if s.dialect != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,168 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+
+	checkpoint, err := store.Load(ctx, "eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if checkpoint != nil {
+		t.Fatalf("expected no checkpoint, got %+v", checkpoint)
+	}
+
+	if err := store.Save(ctx, "eth", &Checkpoint{Height: 10, Hash: "0x0a", Tip: 12, TipHash: "0x0c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(ctx, "btc", &Checkpoint{Height: 5, Hash: "05"}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint, err = store.Load(ctx, "eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !checkpoint.equal(&Checkpoint{Height: 10, Hash: "0x0a", Tip: 12, TipHash: "0x0c"}) {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+}
+
+func TestCheckpoint_Value(t *testing.T) {
+	value, err := Checkpoint{Height: 10, Hash: "0x0a"}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var checkpoint Checkpoint
+	if err := checkpoint.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+
+	if !checkpoint.equal(&Checkpoint{Height: 10, Hash: "0x0a"}) {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+}
+
+func TestSQLCheckpointStore_Query(t *testing.T) {
+	store := NewSQLCheckpointStore(nil, "scanner_checkpoints", DialectPostgres)
+	if query := store.query("SELECT checkpoint FROM %s WHERE chain = ? AND checkpoint = ?"); query != "SELECT checkpoint FROM scanner_checkpoints WHERE chain = $1 AND checkpoint = $2" {
+		t.Fatalf("unexpected postgres query %s", query)
+	}
+
+	store = NewSQLCheckpointStore(nil, "scanner_checkpoints", "oracle")
+	if err := store.Save(context.Background(), "eth", &Checkpoint{}); err == nil {
+		t.Fatal("expected an unknown dialect to be rejected")
+	}
+}
+
+func TestScanner_Resume(t *testing.T) {
+	chain := &fakeBlockchain{}
+	chain.mine("a")
+
+	store := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	config := Config{
+		Confirmations: map[string]int64{"BTC": 3},
+		Start:         &Checkpoint{Height: 0, Hash: "a-0"},
+		Store:         store,
+		Chain:         "test",
+	}
+
+	r := &recorder{}
+	config.Handler = r.handle
+	s, err := New(chain, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain.mine("a", "BTC", "ETH")
+	chain.mine("a", "ETH")
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEvents(t, r.take(), "deposit:a-1-ETH", "deposit:a-2-ETH")
+
+	// a new scanner resume from the store without emitting the same deposits twice
+	restarted := &recorder{}
+	config.Handler = restarted.handle
+	s, err = New(chain, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain.mine("a")
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEvents(t, restarted.take(), "deposit:a-1-BTC")
+}
+
+func TestScanner_ResumeReorg(t *testing.T) {
+	for _, known := range []bool{true, false} {
+		t.Run(fmt.Sprintf("known=%v", known), func(t *testing.T) {
+			chain := &fakeBlockchain{linked: true}
+			chain.mine("a")
+
+			config := Config{
+				Confirmations: map[string]int64{"BTC": 3},
+				Start:         &Checkpoint{Height: 0, Hash: "a-0"},
+				Store:         NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json")),
+				Chain:         "test",
+			}
+
+			r := &recorder{}
+			config.Handler = r.handle
+			s, err := New(chain, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chain.mine("a", "BTC", "ETH")
+			chain.mine("a", "ETH")
+			if _, err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			expectEvents(t, r.take(), "deposit:a-1-ETH", "deposit:a-2-ETH")
+
+			// the stored tip is orphaned while the scanner is stopped
+			chain.fork(1, known)
+			chain.mine("b", "ETH")
+			chain.mine("b")
+
+			s, err = New(chain, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.Scan(context.Background())
+			if !known {
+				if !errors.Is(err, ErrReorgTooDeep) {
+					t.Fatalf("expected ErrReorgTooDeep for an orphan unknown to the node, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectEvents(t, r.take(), "rollback:a-2-ETH", "deposit:a-1-BTC", "deposit:b-2-ETH")
+		})
+	}
+}
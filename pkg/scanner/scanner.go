@@ -0,0 +1,674 @@
+// Package scanner walks the blocks of any blockchain driver, waits for the
+// transactions to reach their required confirmations and reports chain
+// reorganizations.
+//
+// The scanner keeps a window of the latest blocks in memory, a transaction
+// is emitted as an EventDeposit once its block is deep enough and, if its
+// block is later orphaned while still inside the window, an EventRollback
+// is emitted for it. With a CheckpointStore the scanner resume where it
+// stopped after a restart.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/volatiletech/null/v9"
+	"github.com/zsmartex/multichain/pkg/block"
+	"github.com/zsmartex/multichain/pkg/blockchain"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+const (
+	defaultConfirmations = 1
+	defaultMaxReorgDepth = 64
+	defaultBatchSize     = 100
+	defaultPollInterval  = 10 * time.Second
+
+	defaultMaxRetryInterval = 5 * time.Minute
+)
+
+// ErrReorgTooDeep is returned when the chain reorganized further than the blocks kept by the scanner
+var ErrReorgTooDeep = errors.New("reorg is deeper than the scanner window")
+
+type EventType string
+
+const (
+	// EventDeposit a transaction reached the confirmations required by its currency
+	EventDeposit EventType = "deposit"
+	// EventRollback a transaction emitted earlier is no longer part of the chain
+	EventRollback EventType = "rollback"
+)
+
+type Event struct {
+	Type           EventType
+	BlockNumber    int64
+	BlockHash      string
+	BlockTimestamp time.Time
+	Confirmations  int64
+	Transaction    *transaction.Transaction
+}
+
+// Handler is called for every event, the scanner stops and retries the
+// same event on the next scan if an error is returned
+type Handler func(ctx context.Context, event *Event) error
+
+// ChannelHandler returns a Handler which send the events to ch
+func ChannelHandler(ch chan<- *Event) Handler {
+	return func(ctx context.Context, event *Event) error {
+		select {
+		case ch <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+type Config struct {
+	// Confirmations required per currency id before emitting a deposit
+	Confirmations map[string]int64
+	// DefaultConfirmations is used for currencies missing in Confirmations
+	DefaultConfirmations int64
+	// MaxReorgDepth is how many blocks are kept to detect reorgs
+	MaxReorgDepth int64
+	// BatchSize is the maximum number of blocks fetched by a single scan
+	BatchSize int64
+	// PollInterval is the wait between two scans once the chain head is reached
+	PollInterval time.Duration
+	// MaxRetryInterval caps the backoff of Run between two failed scans, the first retry waits PollInterval
+	MaxRetryInterval time.Duration
+	// OnError is called with every error Run retries, optional
+	OnError func(err error)
+	// Start the scan after this block when Store has no checkpoint, nil starts from the latest block
+	Start *Checkpoint
+	// Store persist the checkpoint of Chain after every scan, optional
+	Store   CheckpointStore
+	Chain   string
+	Handler Handler
+}
+
+type scannedBlock struct {
+	number       int64
+	hash         string
+	parentHash   string
+	timestamp    time.Time
+	transactions []*transaction.Transaction
+	emitted      []bool
+}
+
+func (b *scannedBlock) done() bool {
+	for _, emitted := range b.emitted {
+		if !emitted {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Scanner is not safe for concurrent use
+type Scanner struct {
+	blockchain blockchain.Blockchain
+	config     Config
+	cursor     *Checkpoint     // last block whose transactions have all been emitted
+	anchor     *Checkpoint     // block right before the window
+	blocks     []*scannedBlock // contiguous window of blocks after the anchor
+	resume     *Checkpoint     // checkpoint loaded from the store until its tip is scanned again
+	restored   int64           // lowest block of resume whose deposits were rolled back
+	saved      Checkpoint      // last checkpoint saved to the store
+}
+
+func New(bc blockchain.Blockchain, config Config) (*Scanner, error) {
+	if bc == nil {
+		return nil, fmt.Errorf("%w: scanner blockchain is nil", errors.ErrInvalidConfiguration)
+	}
+
+	if config.Handler == nil {
+		return nil, fmt.Errorf("%w: scanner handler is nil", errors.ErrInvalidConfiguration)
+	}
+
+	if config.Store != nil && len(config.Chain) == 0 {
+		return nil, fmt.Errorf("%w: scanner chain is required to use a checkpoint store", errors.ErrInvalidConfiguration)
+	}
+
+	if config.DefaultConfirmations <= 0 {
+		config.DefaultConfirmations = defaultConfirmations
+	}
+
+	if config.MaxReorgDepth <= 0 {
+		config.MaxReorgDepth = defaultMaxReorgDepth
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	if config.MaxRetryInterval <= 0 {
+		config.MaxRetryInterval = defaultMaxRetryInterval
+	}
+
+	if config.MaxRetryInterval < config.PollInterval {
+		config.MaxRetryInterval = config.PollInterval
+	}
+
+	return &Scanner{
+		blockchain: bc,
+		config:     config,
+	}, nil
+}
+
+// start load the checkpoint from the store, then fallback to Start and to the latest block
+func (s *Scanner) start(ctx context.Context, latest int64) error {
+	checkpoint := &Checkpoint{Height: latest - 1}
+
+	if s.config.Store != nil {
+		stored, err := s.config.Store.Load(ctx, s.config.Chain)
+		if err != nil {
+			return err
+		}
+
+		if stored != nil {
+			checkpoint = stored
+			s.saved = *stored
+
+			if stored.Tip > stored.Height {
+				resume := *stored
+				s.resume = &resume
+				s.restored = stored.Tip + 1
+			}
+		} else if s.config.Start != nil {
+			checkpoint = s.config.Start
+		}
+	} else if s.config.Start != nil {
+		checkpoint = s.config.Start
+	}
+
+	s.cursor = &Checkpoint{Height: checkpoint.Height, Hash: checkpoint.Hash}
+	s.anchor = &Checkpoint{Height: checkpoint.Height, Hash: checkpoint.Hash}
+
+	return nil
+}
+
+// Checkpoint returns where the scanner would resume after a restart
+func (s *Scanner) Checkpoint() *Checkpoint {
+	if s.cursor == nil {
+		return nil
+	}
+
+	checkpoint := *s.cursor
+	if len(s.blocks) > 0 {
+		tip := s.blocks[len(s.blocks)-1]
+		checkpoint.Tip = tip.number
+		checkpoint.TipHash = tip.hash
+	}
+
+	for _, blk := range s.blocks {
+		if blk.number > checkpoint.Height {
+			checkpoint.Hashes = append(checkpoint.Hashes, blk.hash)
+		}
+	}
+
+	return &checkpoint
+}
+
+// Run validate the blockchain when the driver is a blockchain.Validator and scan
+// until ctx is done. Failed scans, such as unavailable nodes, blocks missing on a
+// lagging node or handler errors, are retried with an exponential backoff, only
+// ErrReorgTooDeep stops the scanner as it can't be recovered from
+func (s *Scanner) Run(ctx context.Context) error {
+	if err := s.validate(ctx); err != nil {
+		return err
+	}
+
+	failures := 0
+	for {
+		behind, err := s.Scan(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if errors.Is(err, ErrReorgTooDeep) {
+			return err
+		}
+
+		var wait time.Duration
+		if err != nil {
+			if s.config.OnError != nil {
+				s.config.OnError(err)
+			}
+
+			wait = s.backoff(failures)
+			failures++
+		} else {
+			failures = 0
+			if !behind {
+				wait = s.config.PollInterval
+			}
+		}
+
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// backoff returns the wait before retrying a scan which failed failures times in a row
+func (s *Scanner) backoff(failures int) time.Duration {
+	wait := s.config.PollInterval
+	for i := 0; i < failures && wait < s.config.MaxRetryInterval; i++ {
+		wait *= 2
+	}
+
+	if wait > s.config.MaxRetryInterval {
+		return s.config.MaxRetryInterval
+	}
+
+	return wait
+}
+
+func (s *Scanner) validate(ctx context.Context) error {
+	validator, ok := s.blockchain.(blockchain.Validator)
+	if !ok {
+		return nil
+	}
+
+	for {
+		err := validator.Validate(ctx)
+		if err == nil || !errors.Is(err, errors.ErrNodeUnavailable) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.config.PollInterval):
+		}
+	}
+}
+
+// Scan fetch at most BatchSize new blocks and emit the events,
+// behind is true while there are more blocks to fetch
+func (s *Scanner) Scan(ctx context.Context) (behind bool, err error) {
+	latest, err := s.blockchain.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if s.cursor == nil {
+		if err := s.start(ctx, latest); err != nil {
+			return false, err
+		}
+	}
+
+	if err := s.verify(ctx); err != nil {
+		return false, err
+	}
+
+	next := s.next()
+	last := next + s.config.BatchSize - 1
+	if last > latest {
+		last = latest
+	}
+
+	for number := next; number <= last; number++ {
+		blk, err := s.blockchain.GetBlockByNumber(ctx, number)
+		if err != nil {
+			return false, err
+		}
+
+		blk, err = s.link(ctx, blk)
+		if err != nil {
+			return false, err
+		}
+
+		s.blocks = append(s.blocks, &scannedBlock{
+			number:       blk.Number,
+			hash:         blk.Hash,
+			parentHash:   blk.ParentHash,
+			timestamp:    blk.Timestamp,
+			transactions: blk.Transactions,
+			emitted:      make([]bool, len(blk.Transactions)),
+		})
+
+		// the window may have been rewound by a reorg
+		number = blk.Number
+	}
+
+	if err := s.restore(ctx); err != nil {
+		return false, err
+	}
+
+	if s.resume == nil {
+		if err := s.emit(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	s.advance()
+	s.prune()
+
+	if err := s.save(ctx); err != nil {
+		return false, err
+	}
+
+	return last < latest, nil
+}
+
+// restore mark the transactions emitted before the restart once the tip of
+// the stored checkpoint is scanned again, nothing is emitted until then
+func (s *Scanner) restore(ctx context.Context) error {
+	if s.resume == nil || len(s.blocks) == 0 || s.blocks[len(s.blocks)-1].number < s.resume.Tip {
+		return nil
+	}
+
+	// walk back the stored blocks until one is still part of the chain,
+	// the deposits emitted from the orphaned ones are rolled back
+	for number := s.restored - 1; number > s.resume.Height; number-- {
+		blk := s.scanned(number)
+		if blk == nil || blk.hash == s.resume.hash(number) {
+			break
+		}
+
+		if err := s.rollbackStored(ctx, number); err != nil {
+			return err
+		}
+
+		s.restored = number
+	}
+
+	for _, blk := range s.blocks {
+		if blk.number >= s.restored {
+			break
+		}
+
+		confirmations := s.resume.Tip - blk.number + 1
+
+		for i, tx := range blk.transactions {
+			if confirmations >= s.confirmations(tx.Currency) {
+				blk.emitted[i] = true
+			}
+		}
+	}
+
+	s.resume = nil
+
+	return nil
+}
+
+// rollbackStored emit a rollback for the deposits emitted before the restart from the orphaned
+// block number of the stored checkpoint, the node must still know the block by its hash
+func (s *Scanner) rollbackStored(ctx context.Context, number int64) error {
+	hash := s.resume.hash(number)
+	if len(hash) == 0 {
+		return fmt.Errorf("%w: checkpoint block %d is orphaned", ErrReorgTooDeep, number)
+	}
+
+	blk, err := s.blockchain.GetBlockByHash(ctx, hash)
+	if errors.Is(err, errors.ErrBlockNotFound) {
+		return fmt.Errorf("%w: checkpoint block %d is orphaned and unknown to the node", ErrReorgTooDeep, number)
+	} else if err != nil {
+		return err
+	}
+
+	confirmations := s.resume.Tip - number + 1
+	for _, tx := range blk.Transactions {
+		if confirmations < s.confirmations(tx.Currency) {
+			continue
+		}
+
+		if err := s.config.Handler(ctx, &Event{
+			Type:           EventRollback,
+			BlockNumber:    number,
+			BlockHash:      hash,
+			BlockTimestamp: blk.Timestamp,
+			Transaction:    withConfirmations(tx, 0),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanned returns the block number of the window, nil when it isn't in the window
+func (s *Scanner) scanned(number int64) *scannedBlock {
+	for _, blk := range s.blocks {
+		if blk.number == number {
+			return blk
+		}
+	}
+
+	return nil
+}
+
+// save the checkpoint to the store when it changed
+func (s *Scanner) save(ctx context.Context) error {
+	if s.config.Store == nil || s.resume != nil {
+		return nil
+	}
+
+	checkpoint := s.Checkpoint()
+	if checkpoint.equal(&s.saved) {
+		return nil
+	}
+
+	if err := s.config.Store.Save(ctx, s.config.Chain, checkpoint); err != nil {
+		return err
+	}
+
+	s.saved = *checkpoint
+
+	return nil
+}
+
+// next returns the number of the next block to fetch
+func (s *Scanner) next() int64 {
+	if len(s.blocks) > 0 {
+		return s.blocks[len(s.blocks)-1].number + 1
+	}
+
+	return s.anchor.Height + 1
+}
+
+// link check that blk extends the window, when it doesn't the orphaned blocks are
+// rolled back and the blocks of the new branch are fetched until one connects
+func (s *Scanner) link(ctx context.Context, blk *block.Block) (*block.Block, error) {
+	for len(blk.ParentHash) > 0 {
+		parentHash := s.anchor.Hash
+		if len(s.blocks) > 0 {
+			parentHash = s.blocks[len(s.blocks)-1].hash
+		}
+
+		if len(parentHash) == 0 || parentHash == blk.ParentHash {
+			return blk, nil
+		}
+
+		if len(s.blocks) == 0 {
+			return nil, fmt.Errorf("%w: block %d is orphaned", ErrReorgTooDeep, s.anchor.Height)
+		}
+
+		tip := s.blocks[len(s.blocks)-1]
+		if err := s.rollback(ctx, tip); err != nil {
+			return nil, err
+		}
+
+		var err error
+		blk, err = s.blockchain.GetBlockByNumber(ctx, tip.number)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return blk, nil
+}
+
+// verify check that the tip of the window is still part of the chain for
+// drivers which don't report parent hashes, orphaned blocks are removed and
+// their emitted transactions rolled back
+func (s *Scanner) verify(ctx context.Context) error {
+	if len(s.blocks) > 0 && len(s.blocks[len(s.blocks)-1].parentHash) > 0 {
+		return nil
+	}
+
+	for len(s.blocks) > 0 {
+		tip := s.blocks[len(s.blocks)-1]
+
+		blk, err := s.blockchain.GetBlockByNumber(ctx, tip.number)
+		if err != nil && !errors.Is(err, errors.ErrBlockNotFound) {
+			return err
+		}
+
+		if blk != nil && blk.Hash == tip.hash {
+			return nil
+		}
+
+		if err := s.rollback(ctx, tip); err != nil {
+			return err
+		}
+	}
+
+	// the whole window is orphaned or nothing was scanned yet,
+	// the anchor must still be part of the chain to continue from it
+	if len(s.anchor.Hash) == 0 {
+		return nil
+	}
+
+	blk, err := s.blockchain.GetBlockByNumber(ctx, s.anchor.Height)
+	if err != nil {
+		return err
+	}
+
+	if blk.Hash != s.anchor.Hash {
+		return fmt.Errorf("%w: block %d is orphaned", ErrReorgTooDeep, s.anchor.Height)
+	}
+
+	return nil
+}
+
+// rollback remove the tip of the window and emit a rollback for its emitted transactions
+func (s *Scanner) rollback(ctx context.Context, tip *scannedBlock) error {
+	for i, tx := range tip.transactions {
+		if !tip.emitted[i] {
+			continue
+		}
+
+		if err := s.config.Handler(ctx, &Event{
+			Type:           EventRollback,
+			BlockNumber:    tip.number,
+			BlockHash:      tip.hash,
+			BlockTimestamp: tip.timestamp,
+			Transaction:    withConfirmations(tx, 0),
+		}); err != nil {
+			return err
+		}
+
+		tip.emitted[i] = false
+	}
+
+	s.blocks = s.blocks[:len(s.blocks)-1]
+
+	if s.cursor.Height >= tip.number {
+		if len(s.blocks) > 0 {
+			previous := s.blocks[len(s.blocks)-1]
+			s.cursor = &Checkpoint{Height: previous.number, Hash: previous.hash}
+		} else {
+			anchor := *s.anchor
+			s.cursor = &anchor
+		}
+	}
+
+	return nil
+}
+
+// emit the deposits of every transaction which reached its confirmations,
+// confirmations are counted up to the tip of the window which was verified
+func (s *Scanner) emit(ctx context.Context) error {
+	if len(s.blocks) == 0 {
+		return nil
+	}
+
+	tip := s.blocks[len(s.blocks)-1].number
+
+	for _, blk := range s.blocks {
+		confirmations := tip - blk.number + 1
+
+		for i, tx := range blk.transactions {
+			if blk.emitted[i] || confirmations < s.confirmations(tx.Currency) {
+				continue
+			}
+
+			if err := s.config.Handler(ctx, &Event{
+				Type:           EventDeposit,
+				BlockNumber:    blk.number,
+				BlockHash:      blk.hash,
+				BlockTimestamp: blk.timestamp,
+				Confirmations:  confirmations,
+				Transaction:    withConfirmations(tx, confirmations),
+			}); err != nil {
+				return err
+			}
+
+			blk.emitted[i] = true
+		}
+	}
+
+	return nil
+}
+
+// withConfirmations return a copy of tx with its confirmations when the event is emitted,
+// an orphaned transaction has no confirmation
+func withConfirmations(tx *transaction.Transaction, confirmations int64) *transaction.Transaction {
+	event := *tx
+	event.Confirmations = null.Int64From(confirmations)
+
+	return &event
+}
+
+func (s *Scanner) confirmations(currencyID string) int64 {
+	if confirmations, ok := s.config.Confirmations[currencyID]; ok && confirmations > 0 {
+		return confirmations
+	}
+
+	return s.config.DefaultConfirmations
+}
+
+// advance move the cursor over the blocks whose transactions have all been emitted
+func (s *Scanner) advance() {
+	for _, blk := range s.blocks {
+		if blk.number <= s.cursor.Height {
+			continue
+		}
+
+		if blk.number != s.cursor.Height+1 || !blk.done() {
+			return
+		}
+
+		s.cursor = &Checkpoint{Height: blk.number, Hash: blk.hash}
+	}
+}
+
+// prune drop the blocks which are behind the cursor and too deep to be reorganized,
+// the tip is always kept to verify the chain on the next scan
+func (s *Scanner) prune() {
+	for len(s.blocks) > 1 {
+		blk := s.blocks[0]
+		tip := s.blocks[len(s.blocks)-1]
+		if blk.number > s.cursor.Height || tip.number-blk.number < s.config.MaxReorgDepth {
+			break
+		}
+
+		s.anchor = &Checkpoint{Height: blk.number, Hash: blk.hash}
+		s.blocks = s.blocks[1:]
+	}
+}
@@ -0,0 +1,281 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/volatiletech/null/v9"
+
+	"github.com/zsmartex/multichain/pkg/block"
+	"github.com/zsmartex/multichain/pkg/blockchain"
+	"github.com/zsmartex/multichain/pkg/errors"
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+type fakeBlockchain struct {
+	blockchain.Blockchain
+	blocks  []*block.Block
+	orphans []*block.Block // blocks replaced by a reorg the node still knows by hash
+	linked  bool           // report parent hashes
+	missing int            // GetBlockByNumber calls failing as on a lagging node
+}
+
+func (f *fakeBlockchain) GetLatestBlockNumber(context.Context) (int64, error) {
+	return int64(len(f.blocks) - 1), nil
+}
+
+func (f *fakeBlockchain) GetBlockByNumber(_ context.Context, number int64) (*block.Block, error) {
+	if number < 0 || number >= int64(len(f.blocks)) || f.missing > 0 {
+		f.missing--
+		return nil, errors.ErrBlockNotFound
+	}
+
+	return f.blocks[number], nil
+}
+
+func (f *fakeBlockchain) GetBlockByHash(_ context.Context, hash string) (*block.Block, error) {
+	for _, blk := range append(f.blocks, f.orphans...) {
+		if blk.Hash == hash {
+			return blk, nil
+		}
+	}
+
+	return nil, errors.ErrBlockNotFound
+}
+
+// fork orphan the blocks after number, they stay known by hash when known is true
+func (f *fakeBlockchain) fork(number int64, known bool) {
+	if known {
+		f.orphans = append(f.orphans, f.blocks[number+1:]...)
+	}
+
+	f.blocks = f.blocks[:number+1]
+}
+
+// mine append a block with one transaction of each currency
+func (f *fakeBlockchain) mine(branch string, currencies ...string) {
+	number := int64(len(f.blocks))
+	blk := &block.Block{
+		Number: number,
+		Hash:   fmt.Sprintf("%s-%d", branch, number),
+	}
+
+	if f.linked && number > 0 {
+		blk.ParentHash = f.blocks[number-1].Hash
+	}
+
+	for _, c := range currencies {
+		blk.Transactions = append(blk.Transactions, &transaction.Transaction{
+			Currency:    c,
+			BlockNumber: number,
+			TxHash:      null.StringFrom(fmt.Sprintf("%s-%d-%s", branch, number, c)),
+		})
+	}
+
+	f.blocks = append(f.blocks, blk)
+}
+
+type recorder struct {
+	events []*Event
+}
+
+func (r *recorder) handle(_ context.Context, event *Event) error {
+	r.events = append(r.events, event)
+
+	return nil
+}
+
+func (r *recorder) take() []string {
+	list := make([]string, 0, len(r.events))
+	for _, e := range r.events {
+		list = append(list, fmt.Sprintf("%s:%s", e.Type, e.Transaction.TxHash.String))
+	}
+	r.events = nil
+
+	return list
+}
+
+func expectEvents(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestScanner_Confirmations(t *testing.T) {
+	chain := &fakeBlockchain{}
+	chain.mine("a")
+
+	r := &recorder{}
+	s, err := New(chain, Config{
+		Confirmations: map[string]int64{"BTC": 3},
+		Start:         &Checkpoint{Height: 0, Hash: "a-0"},
+		Handler:       r.handle,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain.mine("a", "BTC", "ETH")
+	chain.mine("a")
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if tx := r.events[0].Transaction; tx.Confirmations != null.Int64From(2) || r.events[0].Confirmations != 2 {
+		t.Fatalf("expected the deposit to have 2 confirmations, got %v", tx.Confirmations)
+	}
+
+	expectEvents(t, r.take(), "deposit:a-1-ETH")
+
+	chain.mine("a")
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if tx := r.events[0].Transaction; tx.Confirmations != null.Int64From(3) {
+		t.Fatalf("expected the deposit to have 3 confirmations, got %v", tx.Confirmations)
+	}
+
+	expectEvents(t, r.take(), "deposit:a-1-BTC")
+
+	if cp := s.Checkpoint(); cp.Height != 3 || cp.Hash != "a-3" {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+}
+
+func TestScanner_Reorg(t *testing.T) {
+	for _, linked := range []bool{false, true} {
+		t.Run(fmt.Sprintf("linked=%v", linked), func(t *testing.T) {
+			chain := &fakeBlockchain{linked: linked}
+			chain.mine("a")
+
+			r := &recorder{}
+			s, err := New(chain, Config{
+				Confirmations: map[string]int64{"BTC": 3},
+				Start:         &Checkpoint{Height: 0, Hash: "a-0"},
+				Handler:       r.handle,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chain.mine("a", "BTC", "ETH")
+			chain.mine("a", "ETH")
+			if _, err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			expectEvents(t, r.take(), "deposit:a-1-ETH", "deposit:a-2-ETH")
+
+			// replace blocks 1 and 2 by a longer branch
+			chain.blocks = chain.blocks[:1]
+			chain.mine("b", "BTC")
+			chain.mine("b")
+			chain.mine("b")
+			if _, err := s.Scan(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if tx := r.events[0].Transaction; tx.Confirmations != null.Int64From(0) {
+				t.Fatalf("expected an orphaned transaction to have no confirmation, got %v", tx.Confirmations)
+			}
+
+			expectEvents(t, r.take(), "rollback:a-2-ETH", "rollback:a-1-ETH", "deposit:b-1-BTC")
+
+		})
+	}
+}
+
+func TestScanner_ReorgTooDeep(t *testing.T) {
+	for _, linked := range []bool{false, true} {
+		t.Run(fmt.Sprintf("linked=%v", linked), func(t *testing.T) {
+			chain := &fakeBlockchain{linked: linked}
+			chain.mine("a")
+			chain.mine("a")
+
+			s, err := New(chain, Config{
+				Start:   &Checkpoint{Height: 1, Hash: "a-1"},
+				Handler: (&recorder{}).handle,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			chain.blocks = chain.blocks[:1]
+			chain.mine("b")
+			chain.mine("b")
+			if _, err := s.Scan(context.Background()); !errors.Is(err, ErrReorgTooDeep) {
+				t.Fatalf("expected ErrReorgTooDeep, got %v", err)
+			}
+
+		})
+	}
+}
+
+type validatingBlockchain struct {
+	fakeBlockchain
+	err error
+}
+
+func (v *validatingBlockchain) Validate(context.Context) error {
+	return v.err
+}
+
+func TestScanner_RunValidate(t *testing.T) {
+	chain := &validatingBlockchain{err: fmt.Errorf("%w: subunits mismatch", errors.ErrInvalidConfiguration)}
+	chain.mine("a")
+
+	s, err := New(chain, Config{Handler: (&recorder{}).handle})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Run(context.Background()); !errors.Is(err, errors.ErrInvalidConfiguration) {
+		t.Fatalf("expected run to refuse an invalid blockchain, got %v", err)
+	}
+}
+
+func TestScanner_RunRetry(t *testing.T) {
+	chain := &fakeBlockchain{missing: 2}
+	chain.mine("a")
+	chain.mine("a", "ETH")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var retried []error
+	failed := false
+	s, err := New(chain, Config{
+		Start:        &Checkpoint{Height: 0, Hash: "a-0"},
+		PollInterval: time.Millisecond,
+		OnError:      func(err error) { retried = append(retried, err) },
+		Handler: func(_ context.Context, event *Event) error {
+			if !failed {
+				failed = true
+				return fmt.Errorf("database is down")
+			}
+
+			cancel()
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected run to stop once canceled, got %v", err)
+	}
+
+	if len(retried) != 3 || !errors.Is(retried[0], errors.ErrBlockNotFound) {
+		t.Fatalf("expected 2 missing blocks and a handler error to be retried, got %v", retried)
+	}
+
+	if s.backoff(0) != time.Millisecond || s.backoff(2) != 4*time.Millisecond || s.backoff(100) != s.config.MaxRetryInterval {
+		t.Fatal("unexpected backoff")
+	}
+}
@@ -32,7 +32,12 @@ type Transaction struct {
 	TxHash      null.String            `json:"tx_hash,omitempty"`
 	TxOut       uint                   `json:"tx_out,omitempty"`
 	Status      Status                 `json:"status,omitempty"`
+	FirstSeen   null.Time              `json:"first_seen,omitempty"` // when a pending transaction entered the mempool
 	Options     map[string]interface{} `json:"options,omitempty"`
+
+	// Confirmations is the number of blocks from the one of the transaction to the head, 0 while
+	// it is pending. GetTransaction set it, it is null when the driver would need another request
+	Confirmations null.Int64 `json:"confirmations,omitempty"`
 }
 
 func (t *Transaction) IsPending() bool {
@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// HDSetting configure the derivation of the wallet addresses from a master key (BIP32),
+// CreateAddress then return the derivation index as secret instead of a private key
+type HDSetting struct {
+	// Master is the master extended private key (xprv) or the hex encoded seed
+	Master string
+
+	// Path is the derivation path of the addresses without their index,
+	// it defaults to the BIP44 path m/<purpose>'/<coin type>'/<account>'/0 of the driver
+	Path string
+
+	// Account is the BIP44 account of the default path
+	Account uint32
+
+	// NextIndex is the index CreateAddress derives next, the caller must persist
+	// the returned indexes and restore it to avoid handing out an address twice
+	NextIndex uint32
+}
+
+// Validate check that the master key and path can be used to derive keys
+func (s *HDSetting) Validate() error {
+	if _, err := s.master(); err != nil {
+		return fmt.Errorf("%w: hd master is invalid: %v", errors.ErrInvalidConfiguration, err)
+	}
+
+	if len(s.Path) > 0 {
+		if _, err := ParsePath(s.Path); err != nil {
+			return fmt.Errorf("%w: hd path is invalid: %v", errors.ErrInvalidConfiguration, err)
+		}
+	}
+
+	return nil
+}
+
+// DerivationPath return Path or the BIP44 path of the purpose and coin type
+func (s *HDSetting) DerivationPath(purpose, coinType uint32) string {
+	if len(s.Path) > 0 {
+		return s.Path
+	}
+
+	return fmt.Sprintf("m/%d'/%d'/%d'/0", purpose, coinType, s.Account)
+}
+
+// DeriveKey derive the private key at index under path
+func (s *HDSetting) DeriveKey(path string, index uint32) (*ecdsa.PrivateKey, error) {
+	key, err := s.master()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range append(indexes, index) {
+		if key, err = key.Derive(i); err != nil {
+			return nil, err
+		}
+	}
+
+	privateKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return privateKey.ToECDSA(), nil
+}
+
+// ExtendedMasterKey return the master key serialized for the network of params, such as
+// xprv on mainnet and tprv on the test networks, to be used in output descriptors
+func (s *HDSetting) ExtendedMasterKey(params *chaincfg.Params) (string, error) {
+	key, err := s.master()
+	if err != nil {
+		return "", err
+	}
+
+	key, err = key.CloneWithVersion(params.HDPrivateKeyID[:])
+	if err != nil {
+		return "", err
+	}
+
+	return key.String(), nil
+}
+
+func (s *HDSetting) master() (*hdkeychain.ExtendedKey, error) {
+	if strings.HasPrefix(s.Master, "xprv") || strings.HasPrefix(s.Master, "tprv") {
+		key, err := hdkeychain.NewKeyFromString(s.Master)
+		if err != nil {
+			return nil, err
+		}
+
+		if key.Depth() != 0 {
+			return nil, fmt.Errorf("extended key has depth %d instead of a master key", key.Depth())
+		}
+
+		return key, nil
+	}
+
+	seed, err := hex.DecodeString(strings.TrimPrefix(s.Master, "0x"))
+	if err != nil {
+		return nil, errors.New("master is neither an extended private key nor a hex seed")
+	}
+
+	// the network only matters to serialize the key
+	return hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+}
+
+// ParsePath parse a derivation path like m/44'/60'/0'/0, hardened indexes are marked by ' or h
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("path %s does not start at the master key", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		var hardened uint32
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") {
+			hardened = hdkeychain.HardenedKeyStart
+			part = part[:len(part)-1]
+		}
+
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("path %s has an invalid index %s", path, part)
+		}
+
+		indexes = append(indexes, uint32(index)+hardened)
+	}
+
+	return indexes, nil
+}
+
+// ParseIndex parse a secret returned by CreateAddress of an HD wallet
+func ParseIndex(secret string) (uint32, bool) {
+	index, err := strconv.ParseUint(secret, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint32(index), true
+}
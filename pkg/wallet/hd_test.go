@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/zsmartex/multichain/pkg/errors"
+)
+
+// seed of the "abandon abandon ... about" mnemonic
+const testSeed = "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4"
+
+func TestHDSetting_DeriveKey(t *testing.T) {
+	// BIP32 test vector 1
+	s := &HDSetting{Master: "000102030405060708090a0b0c0d0e0f"}
+	key, err := s.DeriveKey("m/0'/1/2'", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hex.EncodeToString(crypto.FromECDSA(key)); got != "0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4" {
+		t.Fatalf("unexpected key m/0'/1/2'/2 %s", got)
+	}
+
+	for _, master := range []string{testSeed, "xprv9s21ZrQH143K3GJpoapnV8SFfukcVBSfeCficPSGfubmSFDxo1kuHnLisriDvSnRRuL2Qrg5ggqHKNVpxR86QEC8w35uxmGoggxtQTPvfUu"} {
+		s := &HDSetting{Master: master}
+		key, err := s.DeriveKey(s.DerivationPath(44, 60), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if address := crypto.PubkeyToAddress(key.PublicKey).Hex(); address != "0x9858EfFD232B4033E47d90003D41EC34EcaEda94" {
+			t.Fatalf("unexpected address %s", address)
+		}
+	}
+}
+
+func TestHDSetting_Validate(t *testing.T) {
+	for _, s := range []*HDSetting{
+		{Master: "seed"},
+		{Master: testSeed, Path: "44'/60'/0'/0"},
+		{Master: testSeed, Path: "m/44'/x/0"},
+		{Master: testSeed, Path: "m/2147483648"},
+	} {
+		if err := s.Validate(); !errors.Is(err, errors.ErrInvalidConfiguration) {
+			t.Fatalf("expected %+v to be invalid, got %v", s, err)
+		}
+	}
+
+	if err := (&HDSetting{Master: testSeed, Path: "m/84h/0h/0h/0"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	if index, ok := ParseIndex("42"); !ok || index != 42 {
+		t.Fatalf("expected index 42, got %d %v", index, ok)
+	}
+
+	if _, ok := ParseIndex("0x5ad49a2045e12bdea77b36debea34721aa6941084ef1b75877e56f1bd71e0515"); ok {
+		t.Fatal("expected a private key not to be an index")
+	}
+}
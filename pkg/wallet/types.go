@@ -2,10 +2,12 @@ package wallet
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/shopspring/decimal"
 
 	"github.com/zsmartex/multichain/pkg/currency"
+	"github.com/zsmartex/multichain/pkg/errors"
 	"github.com/zsmartex/multichain/pkg/transaction"
 )
 
@@ -25,10 +27,47 @@ type SettingWallet struct {
 type Setting struct {
 	Wallet   *SettingWallet
 	Currency *currency.Currency
+
+	// HD derive the addresses of CreateAddress from a master key, the wallet secret
+	// may then be the derivation index of the wallet address
+	HD *HDSetting
+}
+
+// Validate check the parts of setting which are present, a wallet is usually
+// configured in several steps (wallet first, then currency), the format of
+// the wallet uri depends on the driver which checks it
+func (s *Setting) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: setting is nil", errors.ErrInvalidConfiguration)
+	}
+
+	if s.Wallet == nil && s.Currency == nil && s.HD == nil {
+		return fmt.Errorf("%w: setting has neither wallet, currency nor hd", errors.ErrInvalidConfiguration)
+	}
+
+	if s.Wallet != nil {
+		if len(s.Wallet.URI) == 0 {
+			return fmt.Errorf("%w: setting wallet uri is empty", errors.ErrInvalidConfiguration)
+		}
+	}
+
+	if s.Currency != nil {
+		if err := s.Currency.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if s.HD != nil {
+		if err := s.HD.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 type Wallet interface {
-	Configure(settings *Setting)
+	Configure(settings *Setting) error
 
 	// CreateAddress Create new address from server
 	CreateAddress(ctx context.Context) (address string, secret string, err error)
@@ -41,5 +80,27 @@ type Wallet interface {
 
 	// PrepareDepositCollection Prepare deposit collection fee for deposit
 	// WARN: this func don't execute create transaction just return transaction was built
+	// The error is errors.ErrNotSupported when the deposit needs no fee to be collected,
+	// such as native currency deposits or deposits to a forwarder
 	PrepareDepositCollection(ctx context.Context, depositTransaction *transaction.Transaction, depositSpreads []*transaction.Transaction, depositCurrency *currency.Currency) (*transaction.Transaction, error)
+
+	// Health check that the node is reachable and able to serve requests
+	Health(ctx context.Context) error
+
+	// Close release the connections held by the driver, Configure must be called before using it again
+	Close() error
+}
+
+// MessageSigner is implemented by wallets able to sign messages with the key of their address,
+// signatures are encoded the way the chain tools expect them (hex on evm and tron, base64 on bitcoin)
+type MessageSigner interface {
+	SignMessage(ctx context.Context, message []byte) (signature string, err error)
+
+	// VerifyMessage check that signature of message was made by the key of address
+	VerifyMessage(ctx context.Context, address string, message []byte, signature string) (bool, error)
+}
+
+// Deriver is implemented by wallets able to derive their addresses from Setting.HD
+type Deriver interface {
+	DeriveAddress(ctx context.Context, index uint32) (address string, err error)
 }
@@ -0,0 +1,181 @@
+// Package watchlist contains sets of addresses used by the drivers to only
+// return the transactions of watched addresses.
+//
+// Hex addresses are compared case-insensitively, the others such as base58
+// addresses are compared as they are since their case is significant.
+package watchlist
+
+import (
+	"hash/maphash"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+type Watchlist interface {
+	Contains(address string) bool
+}
+
+// Set is an exact set of addresses safe for concurrent use
+type Set struct {
+	mu        sync.RWMutex
+	addresses map[string]struct{}
+}
+
+func NewSet(addresses ...string) *Set {
+	s := &Set{
+		addresses: make(map[string]struct{}, len(addresses)),
+	}
+
+	s.Add(addresses...)
+
+	return s
+}
+
+func (s *Set) Add(addresses ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, address := range addresses {
+		s.addresses[normalize(address)] = struct{}{}
+	}
+}
+
+func (s *Set) Remove(addresses ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, address := range addresses {
+		delete(s.addresses, normalize(address))
+	}
+}
+
+func (s *Set) Contains(address string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.addresses[normalize(address)]
+
+	return ok
+}
+
+func (s *Set) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.addresses)
+}
+
+// Bloom is a bloom filter of addresses safe for concurrent use, Contains never
+// misses an added address but may return true for others with the configured rate
+type Bloom struct {
+	mu     sync.RWMutex
+	seeds  [2]maphash.Seed
+	bits   []uint64
+	size   uint64 // number of bits
+	hashes uint64 // number of hash functions
+}
+
+// NewBloom returns a filter sized for expected addresses with the false positive rate,
+// 1 million addresses at 1% take about 1.2MB
+func NewBloom(expected int, falsePositiveRate float64) *Bloom {
+	if expected < 1 {
+		expected = 1
+	}
+
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	size := uint64(math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Max(1, math.Round(float64(size)/float64(expected)*math.Ln2)))
+
+	return &Bloom{
+		seeds:  [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+func (b *Bloom) Add(addresses ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, address := range addresses {
+		h1, h2 := hash(b.seeds[0], address), hash(b.seeds[1], address)
+		for i := uint64(0); i < b.hashes; i++ {
+			bit := (h1 + i*h2) % b.size
+			b.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+}
+
+func (b *Bloom) Contains(address string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	h1, h2 := hash(b.seeds[0], address), hash(b.seeds[1], address)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ContainsAny reports whether any of the addresses is in the watchlist, empty addresses are ignored
+func ContainsAny(w Watchlist, addresses ...string) bool {
+	for _, address := range addresses {
+		if len(address) > 0 && w.Contains(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FilterTransactions keep the transactions from or to a watched address, transactions
+// without any address (failed contract calls) are kept since the drivers only build
+// them for chain transactions already known to concern the watchlist
+func FilterTransactions(w Watchlist, transactions []*transaction.Transaction) []*transaction.Transaction {
+	if w == nil {
+		return transactions
+	}
+
+	filtered := make([]*transaction.Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		if (len(t.FromAddress) == 0 && len(t.ToAddress) == 0) || ContainsAny(w, t.FromAddress, t.ToAddress) {
+			filtered = append(filtered, t)
+		}
+	}
+
+	return filtered
+}
+
+func hash(seed maphash.Seed, address string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.WriteString(normalize(address))
+
+	return h.Sum64()
+}
+
+// normalize lowercase the 0x prefixed hex addresses, the case of the others is significant
+func normalize(address string) string {
+	if len(address) <= 2 || !strings.HasPrefix(address, "0x") && !strings.HasPrefix(address, "0X") {
+		return address
+	}
+
+	for _, c := range address[2:] {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return address
+		}
+	}
+
+	return strings.ToLower(address)
+}
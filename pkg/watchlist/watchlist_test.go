@@ -0,0 +1,76 @@
+package watchlist
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zsmartex/multichain/pkg/transaction"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet("0xF37111De2f6AE2f64Be1e59472b5C50801540C8c")
+
+	if !s.Contains("0xf37111de2f6ae2f64be1e59472b5c50801540c8c") {
+		t.Fatal("expected address to be found case-insensitively")
+	}
+
+	if s.Contains("0xbecFbfd1BaDdB4FE857CCB428555b9EA47fFCC40") {
+		t.Fatal("expected address not to be found")
+	}
+
+	s.Remove("0xF37111De2f6AE2f64Be1e59472b5C50801540C8c")
+	if s.Len() != 0 {
+		t.Fatal("expected set to be empty")
+	}
+
+	// the case of base58 addresses is significant
+	s.Add("TJRabPrwbZy45sbavfcjinPJC18kjpRTv8")
+	if !s.Contains("TJRabPrwbZy45sbavfcjinPJC18kjpRTv8") || s.Contains("tjrabprwbzy45sbavfcjinpjc18kjprtv8") {
+		t.Fatal("expected a base58 address to be compared case-sensitively")
+	}
+}
+
+func TestBloom(t *testing.T) {
+	const n = 10_000
+
+	b := NewBloom(n, 0.01)
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprintf("address-%d", i))
+	}
+
+	for i := 0; i < n; i++ {
+		if !b.Contains(fmt.Sprintf("address-%d", i)) {
+			t.Fatalf("expected address-%d to be found", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if b.Contains(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+
+	if rate := float64(falsePositives) / n; rate > 0.02 {
+		t.Fatalf("false positive rate %f is too high", rate)
+	}
+}
+
+func TestFilterTransactions(t *testing.T) {
+	w := NewSet("0xF37111De2f6AE2f64Be1e59472b5C50801540C8c")
+
+	transactions := []*transaction.Transaction{
+		{FromAddress: "0xbecfbfd1baddb4fe857ccb428555b9ea47ffcc40", ToAddress: "0xf37111de2f6ae2f64be1e59472b5c50801540c8c"},
+		{FromAddress: "0xbecfbfd1baddb4fe857ccb428555b9ea47ffcc40", ToAddress: "0x0000000000000000000000000000000000000001"},
+		{Status: transaction.StatusFailed},
+	}
+
+	filtered := FilterTransactions(w, transactions)
+	if len(filtered) != 2 || filtered[0] != transactions[0] || filtered[1] != transactions[2] {
+		t.Fatalf("unexpected filtered transactions: %v", filtered)
+	}
+
+	if len(FilterTransactions(nil, transactions)) != len(transactions) {
+		t.Fatal("expected nil watchlist to keep every transaction")
+	}
+}
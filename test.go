@@ -4,22 +4,28 @@ import (
 	"context"
 	"log"
 
-	"github.com/zsmartex/multichain/chains/tron"
+	_ "github.com/zsmartex/multichain/chains"
 	"github.com/zsmartex/multichain/pkg/blockchain"
 	"github.com/zsmartex/multichain/pkg/currency"
 )
 
 func main() {
-	trxClient := tron.NewBlockchain()
-	trxClient.Configure(&blockchain.Setting{
-		URI: "http://demo.zsmartex.com:8090/",
+	trxClient, err := blockchain.New("tron")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := trxClient.Configure(&blockchain.Setting{
+		URI: "demo.zsmartex.com:50051",
 		Currencies: []*currency.Currency{
 			{
 				ID:       "TRX",
 				Subunits: 6,
 			},
 		},
-	})
+	}); err != nil {
+		log.Fatal(err)
+	}
 
 	n, err := trxClient.GetLatestBlockNumber(context.Background())
 	if err != nil {